@@ -13,18 +13,20 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	openairt "github.com/WqyJh/go-openai-realtime/v2"
 	"github.com/sashabaranov/go-openai/jsonschema"
 	"github.com/richiejp/VoxInput/internal/audio"
 	"github.com/richiejp/VoxInput/internal/gui"
+	"github.com/richiejp/VoxInput/internal/history"
 	"github.com/richiejp/VoxInput/internal/input"
 )
 
 const functionNameInputControl = "input_control"
 const functionNameTakeScreenshot = "take_screenshot"
 
-func (l *Listener) startAssistantSession(ctx context.Context) error {
+func (l *Listener) assistantSessionUpdate() (openairt.SessionUpdateEvent, error) {
 	voice := openairt.Voice("")
 	if l.config.AssistantVoice != "" {
 		voice = openairt.Voice(l.config.AssistantVoice)
@@ -34,7 +36,7 @@ func (l *Listener) startAssistantSession(ctx context.Context) error {
 	if l.config.EnableDotool {
 		schema, err := jsonschema.GenerateSchemaForType(input.CommandParameters{})
 		if err != nil {
-			return fmt.Errorf("generate input control schema: %w", err)
+			return openairt.SessionUpdateEvent{}, fmt.Errorf("generate input control schema: %w", err)
 		}
 
 		schemaJSON, err := json.MarshalIndent(schema, "", "  ")
@@ -56,7 +58,7 @@ func (l *Listener) startAssistantSession(ctx context.Context) error {
 	if l.config.ScreenshotCommand != "" && l.config.ScreenshotFile != "" {
 		screenshotSchema, err := jsonschema.GenerateSchemaForType(input.ScreenshotParameters{})
 		if err != nil {
-			return fmt.Errorf("generate screenshot schema: %w", err)
+			return openairt.SessionUpdateEvent{}, fmt.Errorf("generate screenshot schema: %w", err)
 		}
 
 		tools = append(tools, openairt.ToolUnion{
@@ -77,10 +79,7 @@ func (l *Listener) startAssistantSession(ctx context.Context) error {
 		}
 	}
 
-	return l.conn.SendMessage(ctx, openairt.SessionUpdateEvent{
-		EventBase: openairt.EventBase{
-			EventID: "Initial update",
-		},
+	return openairt.SessionUpdateEvent{
 		Session: openairt.SessionUnion{
 			Realtime: &openairt.RealtimeSession{
 				Instructions:     l.config.Instructions,
@@ -104,7 +103,7 @@ func (l *Listener) startAssistantSession(ctx context.Context) error {
 				Tools: tools,
 			},
 		},
-	})
+	}, nil
 }
 
 func (l *Listener) runAudioAssistant() {
@@ -146,6 +145,10 @@ func (l *Listener) ReceiveAssistantMessages() {
 	// touched solely from this goroutine, so no synchronisation is needed.
 	var responseActive bool
 	var activeResponseID string
+	// For the history: when the user started speaking, how long they spoke
+	// and when the response was created
+	var speechStarted, responseCreated time.Time
+	var spoken time.Duration
 
 	for {
 		msg, err := l.conn.ReadMessage(l.ctx)
@@ -157,6 +160,7 @@ func (l *Listener) ReceiveAssistantMessages() {
 				return
 			}
 			log.Println("Listener.ReceiveAssistantMessages: error receiving message, retrying: ", err)
+			metricReadRetries.Inc()
 			continue
 		}
 		log.Println("Listener.ReceiveAssistantMessages: receiving message: ", msg.ServerEventType())
@@ -164,6 +168,7 @@ func (l *Listener) ReceiveAssistantMessages() {
 		case openairt.ServerEventTypeInputAudioBufferSpeechStarted:
 			log.Println("Listener.ReceiveAssistantMessages: speech detected")
 			l.config.UI.Send(&gui.ShowSpeechDetectedMsg{})
+			speechStarted = time.Now()
 			// Barge-in: the user is talking over the assistant. The local
 			// playback buffer can hold seconds of TTS that arrived in a burst,
 			// so always flush it here regardless of server response state. Only
@@ -174,19 +179,36 @@ func (l *Listener) ReceiveAssistantMessages() {
 		case openairt.ServerEventTypeInputAudioBufferSpeechStopped:
 			log.Println("Listener.ReceiveAssistantMessages: speech stopped, processing")
 			l.config.UI.Send(&gui.ShowSpeechSubmittedMsg{})
+			if !speechStarted.IsZero() {
+				spoken = time.Since(speechStarted)
+			}
 		case openairt.ServerEventTypeResponseCreated:
 			log.Println("Listener.ReceiveAssistantMessages: generating response")
 			responseActive = true
 			activeResponseID = msg.(openairt.ResponseCreatedEvent).Response.ID
+			responseCreated = time.Now()
 			l.config.UI.Send(&gui.ShowGeneratingResponseMsg{})
 		case openairt.ServerEventTypeResponseDone:
 			log.Println("Listener.ReceiveAssistantMessages: response done")
 			responseActive = false
 			activeResponseID = ""
+			l.config.UI.Send(&gui.HideMsg{})
 		case openairt.ServerEventTypeConversationItemInputAudioTranscriptionCompleted:
 			transcript := msg.(openairt.ConversationItemInputAudioTranscriptionCompletedEvent).Transcript
 			log.Printf("Listener.ReceiveAssistantMessages: user said: %s", transcript)
+			metricTranscripts.Inc()
 			l.config.UI.Send(&gui.ShowTranscriptMsg{Text: transcript, IsUser: true})
+			recordHistory(l.liveConfig(), history.Entry{
+				Kind: history.KindTranscript, Text: transcript, DurationMs: spoken.Milliseconds()})
+		case openairt.ServerEventTypeResponseOutputAudioTranscriptDone:
+			reply := msg.(openairt.ResponseOutputAudioTranscriptDoneEvent).Transcript
+			log.Printf("Listener.ReceiveAssistantMessages: assistant said: %s", reply)
+			var took time.Duration
+			if !responseCreated.IsZero() {
+				took = time.Since(responseCreated)
+			}
+			recordHistory(l.liveConfig(), history.Entry{
+				Kind: history.KindAssistant, Text: reply, DurationMs: took.Milliseconds()})
 		case openairt.ServerEventTypeResponseOutputAudioDelta:
 			// Drop deltas once the response has been barged in on; they would
 			// otherwise refill the playback buffer we just flushed.
@@ -203,6 +225,7 @@ func (l *Listener) ReceiveAssistantMessages() {
 			case l.audioPlayChunks <- bytes.NewBuffer(b):
 			default:
 				log.Println("Listener.ReceiveAssistantMessages: dropped audio chunk")
+				metricDroppedChunks.Inc()
 			}
 		case openairt.ServerEventTypeResponseFunctionCallArgumentsDone:
 			event := msg.(openairt.ResponseFunctionCallArgumentsDoneEvent)
@@ -211,6 +234,7 @@ func (l *Listener) ReceiveAssistantMessages() {
 				FunctionName: event.Name,
 				Arguments:    event.Arguments,
 			})
+			toolStart := time.Now()
 			switch event.Name {
 			case functionNameInputControl:
 				var args input.CommandParameters
@@ -219,7 +243,10 @@ func (l *Listener) ReceiveAssistantMessages() {
 					continue
 				}
 
-				if err := l.config.InputController.ExecuteCommands(l.ctx, args.Commands); err != nil {
+				l.inputMu.Lock()
+				err := l.config.InputController.ExecuteCommands(l.ctx, args.Commands)
+				l.inputMu.Unlock()
+				if err != nil {
 					log.Println("Listener.ReceiveAssistantMessages: error executing input commands: ", err)
 					continue
 				}
@@ -245,6 +272,7 @@ func (l *Listener) ReceiveAssistantMessages() {
 					continue
 				}
 			}
+			metricToolCallDuration.ObserveSince(toolStart)
 		case openairt.ServerEventTypeError:
 			log.Println("Listener.ReceiveAssistantMessages: server error: ", msg.(openairt.ErrorEvent).Error.Message)
 			continue
@@ -266,6 +294,7 @@ func (l *Listener) bargeIn(responseActive bool, responseID string) {
 		return
 	}
 	log.Printf("Listener.bargeIn: user interrupted, dropped %d bytes of queued audio", dropped)
+	metricBargeInBytes.Add(float64(dropped))
 
 	if !responseActive {
 		return
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+
+	"github.com/richiejp/VoxInput/internal/config"
+	"github.com/richiejp/VoxInput/internal/ipc"
+	"github.com/richiejp/VoxInput/internal/pid"
+)
+
+var clientCommands = map[string]ipc.CommandKind{
+	"record": ipc.CommandRecord,
+	"stop":   ipc.CommandStop,
+	"write":  ipc.CommandStop,
+	"toggle": ipc.CommandToggle,
+	"status": ipc.CommandStatus,
+	"reload": ipc.CommandReload,
+	"undo":   ipc.CommandUndo,
+}
+
+// clientCommand implements the commands that control a running listener.
+// They are sent over the IPC socket when the listener has one, so toggle
+// is decided by the listener and status reports its real state; otherwise
+// they fall back to signalling the PID in the PID file.
+func clientCommand(cmd string, args []string, pidPath, statePath string) {
+	kind, ok := clientCommands[cmd]
+	if !ok {
+		log.Fatalln("main: Unknown command: ", cmd)
+	}
+
+	if cmd == "status" {
+		if _, ok := config.FlagValue(args, "--format"); ok || config.HasFlag(args, "--follow") {
+			barStatusCommand(args)
+			return
+		}
+	}
+
+	if socketPath := clientSocketPath(args); socketPath != "" {
+		client, err := ipc.Connect(socketPath)
+		if err == nil {
+			defer client.Close()
+			ipcClientCommand(client, cmd, kind)
+			return
+		}
+		log.Println("main: no listener on IPC socket, falling back to signals: ", err)
+	}
+
+	signalClientCommand(cmd, pidPath, statePath)
+}
+
+// clientSocketPath returns the socket configured with --socket,
+// VOXINPUT_SOCKET or the config profile, or else the default socket path
+// of the selected instance if it exists.
+func clientSocketPath(args []string) string {
+	r, err := config.NewResolver(args)
+	if err != nil {
+		log.Fatalln("main: ", err)
+	}
+	path := r.String(config.Setting{Key: "socket", Env: []string{"VOXINPUT_SOCKET"}, Flag: "--socket"})
+	if path != "" {
+		return path
+	}
+	instance, err := instanceFrom(r)
+	if err != nil {
+		log.Fatalln("main: ", err)
+	}
+	if _, err := os.Stat(ipc.SocketPath(instance)); err == nil {
+		return ipc.SocketPath(instance)
+	}
+	return ""
+}
+
+func ipcClientCommand(client *ipc.Client, cmd string, kind ipc.CommandKind) {
+	reply, err := client.Request(ipc.Command{Kind: kind}, ipc.RequestTimeout)
+	if err != nil {
+		log.Fatalln("main: ", err)
+	}
+
+	if kind == ipc.CommandStatus {
+		fmt.Println(reply.Text)
+		return
+	}
+	if kind == ipc.CommandUndo {
+		log.Printf("main: undo done, %s", reply.Text)
+		return
+	}
+	log.Printf("main: %s done, listener is %s", cmd, reply.Text)
+}
+
+func signalClientCommand(cmd, pidPath, statePath string) {
+	id, err := pid.Read(pidPath)
+	if err != nil {
+		log.Fatalln("main: failed to read listener PID: ", err)
+	}
+
+	proc, err := os.FindProcess(id)
+	if err != nil {
+		log.Fatalln("main: Failed to find listen process: ", err)
+	}
+
+	switch cmd {
+	case "record":
+		log.Println("main: Sending record signal")
+		err = proc.Signal(syscall.SIGUSR1)
+	case "stop":
+		fallthrough
+	case "write":
+		log.Println("main: Sending stop/write signal")
+		err = proc.Signal(syscall.SIGUSR2)
+	case "reload":
+		log.Println("main: Sending reload signal")
+		err = proc.Signal(syscall.SIGHUP)
+	case "undo":
+		log.Fatalln("main: undo needs a listener with an IPC socket, start listen with --socket")
+	case "status":
+		err = proc.Signal(syscall.Signal(0))
+		if err != nil {
+			log.Fatalln("main: Failed to signal listen process: ", err)
+		}
+
+		recording, err := pid.ReadState(statePath)
+		if err != nil {
+			log.Fatalln("main: Failed to read state file: ", err)
+		}
+
+		if recording {
+			fmt.Println("recording")
+		} else {
+			fmt.Println("idle")
+		}
+	case "toggle":
+		// Read current state
+		recording, readErr := pid.ReadState(statePath)
+		if readErr != nil {
+			log.Fatalln("main: Failed to read state: ", readErr)
+		}
+
+		if recording {
+			log.Println("main: Currently recording, sending stop signal")
+			err = proc.Signal(syscall.SIGUSR2)
+		} else {
+			log.Println("main: Currently idle, sending record signal")
+			err = proc.Signal(syscall.SIGUSR1)
+		}
+	}
+
+	if err != nil {
+		log.Fatalln("main: Error sending signal: ", err)
+	}
+}
+
+// setCommand implements `voxinput set`, which changes settings of the
+// running listener over the IPC socket.
+func setCommand(args []string) {
+	var setArgs ipc.SetArgs
+	flags := []struct {
+		name string
+		dst  **string
+	}{
+		{"--lang", &setArgs.Lang},
+		{"--prompt", &setArgs.Prompt},
+		{"--model", &setArgs.Model},
+		{"--mode", &setArgs.Mode},
+		{"--instructions", &setArgs.Instructions},
+	}
+	changed := false
+	for _, f := range flags {
+		if v, ok := config.FlagValue(args, f.name); ok {
+			*f.dst = &v
+			changed = true
+		}
+	}
+	if !changed {
+		log.Fatalln("set: expected at least one of --lang, --prompt, --model, --mode or --instructions")
+	}
+
+	socketPath := clientSocketPath(args)
+	if socketPath == "" {
+		log.Fatalln("set: no IPC socket found; start listen with --socket or set VOXINPUT_SOCKET")
+	}
+	client, err := ipc.Connect(socketPath)
+	if err != nil {
+		log.Fatalln("set: ", err)
+	}
+	defer client.Close()
+
+	cmd := client.NewCommand(ipc.CommandSet)
+	if cmd.Args, err = json.Marshal(setArgs); err != nil {
+		log.Fatalln("set: ", err)
+	}
+	reply, err := client.Request(cmd, ipc.RequestTimeout)
+	if err != nil {
+		log.Fatalln("set: ", err)
+	}
+	if reply.Text != "" {
+		log.Println("set: ", reply.Text)
+	}
+	if s := reply.Settings; s != nil {
+		fmt.Printf("lang: %q\nprompt: %q\ntranscription_model: %q\nmode: %q\nassistant_instructions: %q\n",
+			s.Lang, s.Prompt, s.Model, s.Mode, s.Instructions)
+	}
+}
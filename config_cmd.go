@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/richiejp/VoxInput/internal/config"
+)
+
+const redacted = "<redacted>"
+
+type configShowValue struct {
+	Key    string            `json:"key"`
+	Value  string            `json:"value"`
+	Source config.SourceKind `json:"source"`
+	Name   string            `json:"name,omitempty"`
+	Note   string            `json:"note,omitempty"`
+}
+
+type configShowOutput struct {
+	ConfigFile string            `json:"config_file,omitempty"`
+	Profile    string            `json:"profile,omitempty"`
+	Settings   []configShowValue `json:"settings"`
+}
+
+// configCommand implements `voxinput config show`, which resolves the
+// listen settings exactly as `listen` would and prints where each came from.
+func configCommand(args []string) {
+	if len(args) < 1 || args[0] != "show" {
+		log.Fatalln("config: expected 'show' subcommand")
+	}
+	args = args[1:]
+
+	_, r, err := resolveListenOptions(args)
+	if err != nil {
+		log.Fatalln("config: ", err)
+	}
+
+	out := configShowOutput{
+		ConfigFile: r.Path(),
+		Profile:    r.ProfileName(),
+	}
+	for _, v := range r.Values() {
+		val := v.Value
+		if v.Secret && val != "" {
+			val = redacted
+		}
+		out.Settings = append(out.Settings, configShowValue{
+			Key:    v.Key,
+			Value:  val,
+			Source: v.Source.Kind,
+			Name:   v.Source.Name,
+			Note:   v.Note,
+		})
+	}
+
+	if config.HasFlag(args, "--json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(out); err != nil {
+			log.Fatalln("config: ", err)
+		}
+		return
+	}
+
+	if out.ConfigFile != "" {
+		fmt.Printf("Config file: %s\n", out.ConfigFile)
+	} else {
+		fmt.Println("Config file: none")
+	}
+	if out.Profile != "" {
+		fmt.Printf("Profile: %s\n", out.Profile)
+	}
+	fmt.Println()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, v := range out.Settings {
+		src := string(v.Source)
+		if v.Name != "" {
+			src += " " + v.Name
+		}
+		if v.Note != "" {
+			src += " (" + v.Note + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%q\t%s\n", v.Key, v.Value, src)
+	}
+	if err := tw.Flush(); err != nil {
+		log.Fatalln("config: ", err)
+	}
+}
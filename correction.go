@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/correction"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newCorrector returns the transcript corrector for a session, or nil if
+// correction is disabled. It uses the same API as HTTP transcription.
+func newCorrector(config ListenConfig) *correction.Corrector {
+	if !config.Correction {
+		return nil
+	}
+
+	var glossary []string
+	for _, term := range strings.Split(config.CorrectionGlossary, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			glossary = append(glossary, term)
+		}
+	}
+
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	clientConfig.BaseURL = config.HTTPAPIBase
+	return correction.New(openai.NewClientWithConfig(clientConfig), correction.Config{
+		Model:    config.CorrectionModel,
+		Glossary: glossary,
+		Timeout:  config.CorrectionTimeout,
+	})
+}
+
+// correctTranscript returns the corrected transcript, or text if there is
+// no corrector or it failed.
+func correctTranscript(ctx context.Context, c *correction.Corrector, text string) string {
+	if c == nil {
+		return text
+	}
+	start := time.Now()
+	corrected, err := c.Correct(ctx, text)
+	metricCorrectionDuration.ObserveSince(start)
+	if err != nil {
+		metricCorrectionFallbacks.Inc()
+		log.Println("correctTranscript: using the raw transcript: ", err)
+		return corrected
+	}
+	if corrected != text {
+		log.Printf("correctTranscript: corrected %q to %q", text, corrected)
+	}
+	return corrected
+}
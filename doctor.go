@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	openairt "github.com/WqyJh/go-openai-realtime/v2"
+	"github.com/gen2brain/malgo"
+
+	"github.com/richiejp/VoxInput/internal/audio"
+	"github.com/richiejp/VoxInput/internal/input"
+	"github.com/richiejp/VoxInput/internal/localvqe"
+)
+
+// errSkip marks a check that does not apply to the resolved configuration.
+var errSkip = errors.New("skipped")
+
+// doctorCheck is one self-check. run returns a short detail on success,
+// errSkip (optionally wrapped with a reason) when the check does not apply,
+// or an error which is printed together with hint.
+type doctorCheck struct {
+	name string
+	hint string
+	run  func() (string, error)
+}
+
+func skip(reason string) (string, error) {
+	return "", fmt.Errorf("%w: %s", errSkip, reason)
+}
+
+// doctorCommand implements `voxinput doctor`. It resolves the listen
+// settings like `listen` does and checks everything listen depends on,
+// printing a remediation hint for each failure.
+func doctorCommand(args []string) {
+	opts, _, err := resolveListenOptions(args)
+	if err != nil {
+		log.Fatalln("doctor: ", err)
+	}
+	c := opts.Config
+	assistantAEC := opts.Realtime && c.Mode == "assistant" && c.EnableAEC
+	needsInput := typesText(c) || c.EnableDotool
+
+	mctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		log.Fatalln("doctor: failed to initialise audio: ", err)
+	}
+	defer func() {
+		_ = mctx.Uninit()
+		mctx.Free()
+	}()
+
+	checks := []doctorCheck{
+		{
+			name: "dotool on PATH",
+			hint: "install dotool (https://git.sr.ht/~geb/dotool) or set --output to write text elsewhere, e.g. --output file --output-file <path>",
+			run: func() (string, error) {
+				if runtime.GOOS != "linux" {
+					return skip("only used on Linux")
+				}
+				if !needsInput {
+					return skip("text is not typed")
+				}
+				return exec.LookPath("dotool")
+			},
+		},
+		{
+			name: "/dev/uinput writable",
+			hint: "add your user to the input group and install the udev rule from the README, then log in again",
+			run: func() (string, error) {
+				if runtime.GOOS != "linux" {
+					return skip("only used on Linux")
+				}
+				if !needsInput {
+					return skip("text is not typed")
+				}
+				f, err := os.OpenFile("/dev/uinput", os.O_WRONLY, 0)
+				if err != nil {
+					return "", err
+				}
+				return "", f.Close()
+			},
+		},
+		{
+			name: "accessibility permission",
+			hint: "enable the terminal or VoxInput in System Settings > Privacy & Security > Accessibility",
+			run: func() (string, error) {
+				if runtime.GOOS != "darwin" {
+					return skip("only used on macOS")
+				}
+				if !needsInput {
+					return skip("text is not typed")
+				}
+				ctrl, err := input.New()
+				if err != nil {
+					return "", err
+				}
+				return "", ctrl.Close()
+			},
+		},
+		{
+			name: "capture device",
+			hint: "run 'voxinput devices' and set VOXINPUT_CAPTURE_DEVICE to one of the listed names",
+			run: func() (string, error) {
+				return checkCaptureDevice(&mctx.Context, c.CaptureDevice)
+			},
+		},
+		{
+			name: "AEC monitor device",
+			hint: "run 'voxinput devices' and set VOXINPUT_AEC_MONITOR_DEVICE to a monitor/loopback capture device",
+			run: func() (string, error) {
+				if !assistantAEC || c.AECRefSource != AECRefMonitor {
+					return skip("AEC reference source is not 'monitor'")
+				}
+				if c.AECMonitorDevice == "" {
+					return "", errors.New("VOXINPUT_AEC_MONITOR_DEVICE is not set")
+				}
+				return checkCaptureDevice(&mctx.Context, c.AECMonitorDevice)
+			},
+		},
+		{
+			name: "LocalVQE library and model",
+			hint: "build with CMake to bundle liblocalvqe, set VOXINPUT_LOCALVQE_LIB / VOXINPUT_LOCALVQE_MODEL, or disable AEC with --no-aec",
+			run: func() (string, error) {
+				if !assistantAEC {
+					return skip("AEC is only used in assistant mode")
+				}
+				libPath, err := localvqe.EnsureLib(c.LocalVQELibPath)
+				if err != nil {
+					return "", fmt.Errorf("find library: %w", err)
+				}
+				modelPath, err := localvqe.EnsureModel(c.LocalVQEModelPath, c.LocalVQEModelVersion)
+				if err != nil {
+					return "", fmt.Errorf("find model: %w", err)
+				}
+				engine, err := localvqe.New(libPath, modelPath)
+				if err != nil {
+					return "", err
+				}
+				defer engine.Close()
+				return fmt.Sprintf("%s with %s (%d Hz)", libPath, modelPath, engine.SampleRate()), nil
+			},
+		},
+		{
+			name: "screenshot command",
+			hint: "check VOXINPUT_ASSISTANT_SCREENSHOT_COMMAND writes to VOXINPUT_ASSISTANT_SCREENSHOT_FILE, e.g. \"grim /tmp/vox-screenshot.png\"",
+			run: func() (string, error) {
+				if c.Mode != "assistant" || c.ScreenshotCommand == "" || c.ScreenshotFile == "" {
+					return skip("screenshot tool is not configured")
+				}
+				return checkScreenshot(c.ScreenshotCommand, c.ScreenshotFile)
+			},
+		},
+		{
+			name: "HTTP API " + c.HTTPAPIBase,
+			hint: "check VOXINPUT_BASE_URL points at a running OpenAI compatible server and VOXINPUT_API_KEY is valid",
+			run: func() (string, error) {
+				return checkHTTPAPI(c.HTTPAPIBase, c.APIKey, c.Timeout)
+			},
+		},
+		{
+			name: "realtime API " + c.WSAPIBase,
+			hint: "check VOXINPUT_WS_BASE_URL and that the server supports the realtime API with the configured assistant model, or use --no-realtime",
+			run: func() (string, error) {
+				if !opts.Realtime {
+					return skip("--no-realtime is set")
+				}
+				return checkRealtimeAPI(c)
+			},
+		},
+	}
+
+	failed := 0
+	for _, check := range checks {
+		detail, err := check.run()
+		switch {
+		case errors.Is(err, errSkip):
+			fmt.Printf("[SKIP] %s: %s\n", check.name, strings.TrimPrefix(err.Error(), errSkip.Error()+": "))
+		case err != nil:
+			failed++
+			fmt.Printf("[FAIL] %s: %v\n", check.name, err)
+			fmt.Printf("       hint: %s\n", check.hint)
+		case detail != "":
+			fmt.Printf("[PASS] %s: %s\n", check.name, detail)
+		default:
+			fmt.Printf("[PASS] %s\n", check.name)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d check(s) failed\n", failed)
+		os.Exit(1)
+	}
+}
+
+func checkCaptureDevice(mctx *malgo.Context, name string) (string, error) {
+	if name == "" {
+		devices, err := mctx.Devices(malgo.Capture)
+		if err != nil {
+			return "", err
+		}
+		for _, d := range devices {
+			if d.IsDefault != 0 {
+				return fmt.Sprintf("system default %q", d.Name()), nil
+			}
+		}
+		if len(devices) == 0 {
+			return "", errors.New("no capture devices found")
+		}
+		return "system default", nil
+	}
+
+	var streamConfig audio.StreamConfig
+	found, err := streamConfig.SetCaptureDeviceByName(mctx, name)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("device %q not found", name)
+	}
+	return fmt.Sprintf("%q", name), nil
+}
+
+func checkScreenshot(command, file string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("the screenshot command is blank")
+	}
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", fmt.Errorf("command succeeded but produced no file: %w", err)
+	}
+	if info.ModTime().Before(start.Add(-time.Second)) {
+		return "", fmt.Errorf("%s was not updated by the command", file)
+	}
+	return fmt.Sprintf("%s (%d bytes)", file, info.Size()), nil
+}
+
+func checkHTTPAPI(base, apiKey string, timeout time.Duration) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(base, "/")+"/models", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", fmt.Errorf("server rejected the API key: %s", resp.Status)
+	case resp.StatusCode >= 500:
+		return "", fmt.Errorf("server error: %s", resp.Status)
+	}
+	return "answered " + resp.Status, nil
+}
+
+func checkRealtimeAPI(c ListenConfig) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	var connOpts []openairt.ConnectOption
+	if c.AssistantModel != "" {
+		connOpts = append(connOpts, openairt.WithModel(c.AssistantModel))
+	}
+	conn, err := newRealtimeClient(c).Connect(ctx, connOpts...)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := waitForSessionUpdated(ctx, conn); err != nil {
+		return "", fmt.Errorf("session not created: %w", err)
+	}
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("session not created: %w", ctx.Err())
+	}
+	return "session created", nil
+}
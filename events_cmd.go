@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/ipc"
+)
+
+// eventKindList returns the kinds of event a client can subscribe to, for
+// the help.
+func eventKindList() string {
+	names := make([]string, len(ipc.EventKinds))
+	for i, k := range ipc.EventKinds {
+		names[i] = string(k)
+	}
+	return strings.Join(names, ", ")
+}
+
+// eventsCommand implements `voxinput events`, which prints the daemon's
+// IPC events to stdout as JSON lines until it disconnects.
+//
+//	--kind <kind>   only print events of this kind; repeatable or comma separated
+//	--once          exit after the first printed event
+//	--until <kind>  exit after printing the first event of this kind
+//	--replay        start with the recent events buffered by the daemon
+func eventsCommand(args []string) {
+	var kinds []ipc.EventKind
+	var until ipc.EventKind
+	var once, replay bool
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--kind", "--until":
+			if i+1 >= len(args) {
+				log.Fatalf("events: %s requires an event kind", args[i])
+			}
+			if args[i] == "--until" {
+				until = ipc.EventKind(args[i+1])
+			} else {
+				for _, k := range strings.Split(args[i+1], ",") {
+					kinds = append(kinds, ipc.EventKind(strings.TrimSpace(k)))
+				}
+			}
+			i++
+		case "--once":
+			once = true
+		case "--replay":
+			replay = true
+		}
+	}
+	if until != "" && kinds != nil && !slices.Contains(kinds, until) {
+		kinds = append(kinds, until)
+	}
+
+	socketPath := clientSocketPath(args)
+	if socketPath == "" {
+		log.Fatalln("events: no IPC socket found; start listen with --socket or set VOXINPUT_SOCKET")
+	}
+	client, err := ipc.Connect(socketPath)
+	if err != nil {
+		log.Fatalln("events: ", err)
+	}
+	defer client.Close()
+
+	// Filtering is also done here in case the daemon is too old to
+	// support subscriptions; then it sends everything.
+	if client.Hello() != nil {
+		if err := client.Subscribe(kinds, replay, 5*time.Second); err != nil {
+			log.Println("events: subscribe failed, filtering locally: ", err)
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for {
+		e, err := client.ReadEvent()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			log.Fatalln("events: ", err)
+		}
+		if e.Kind == ipc.EventReply || (kinds != nil && !slices.Contains(kinds, e.Kind)) {
+			continue
+		}
+		if err := enc.Encode(e); err != nil {
+			log.Fatalln("events: ", err)
+		}
+		if once || e.Kind == until {
+			return
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/config"
+	"github.com/richiejp/VoxInput/internal/history"
+	"github.com/richiejp/VoxInput/internal/ipc"
+)
+
+// defaultHistoryLimit is the number of entries returned by the IPC history
+// command when the client does not ask for a number.
+const defaultHistoryLimit = 20
+
+var historySetting = config.Setting{
+	Key: "history", Env: []string{"VOXINPUT_HISTORY"}, On: "--history", Off: "--no-history", Default: "yes"}
+
+// historyFileSetting returns the history_file setting, which defaults to
+// the file under $XDG_STATE_HOME.
+func historyFileSetting() config.Setting {
+	s := config.Setting{Key: "history_file", Env: []string{"VOXINPUT_HISTORY_FILE"}, Flag: "--history-file"}
+	path, err := history.DefaultPath()
+	if err != nil {
+		log.Println("main: ", err)
+	}
+	s.Default = path
+	return s
+}
+
+// recordHistory appends e to the history file with the session's mode,
+// language and model, unless history is disabled.
+func recordHistory(config ListenConfig, e history.Entry) {
+	if !config.History || config.HistoryFile == "" {
+		return
+	}
+	e.Time = time.Now()
+	e.Mode = config.Mode
+	e.Lang = config.Lang
+	e.Model = config.Model
+	if config.Mode == "assistant" {
+		e.Model = config.AssistantModel
+	}
+	if err := history.Append(config.HistoryFile, e); err != nil {
+		log.Println("recordHistory: ", err)
+	}
+}
+
+// historyFilter converts the arguments shared by the CLI and the IPC
+// command into a filter.
+func historyFilter(since, grep string, limit int) (history.Filter, error) {
+	f := history.Filter{Grep: grep, Limit: limit}
+	if since != "" {
+		t, err := history.ParseSince(since, time.Now())
+		if err != nil {
+			return f, err
+		}
+		f.Since = t
+	}
+	return f, nil
+}
+
+// handleHistory answers an IPC history command with the most recent
+// entries of the history file.
+func handleHistory(cmd ipc.Command, config ListenConfig) {
+	var args ipc.HistoryArgs
+	if len(cmd.Args) > 0 {
+		if err := cmd.DecodeArgs(&args); err != nil {
+			cmd.ReplyError(err)
+			return
+		}
+	}
+	if !config.History {
+		cmd.ReplyError(fmt.Errorf("history is disabled"))
+		return
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultHistoryLimit
+	}
+	f, err := historyFilter(args.Since, args.Grep, args.Limit)
+	if err != nil {
+		cmd.ReplyError(err)
+		return
+	}
+	entries, err := history.Read(config.HistoryFile, f)
+	if err != nil {
+		cmd.ReplyError(err)
+		return
+	}
+	cmd.Reply(ipc.Event{Text: fmt.Sprintf("%d entries", len(entries)), History: entries})
+}
+
+// historyCommand implements `voxinput history`, which prints the
+// transcripts and assistant replies recorded by `listen`.
+//
+//	--since <time>   only entries after a duration ago (2h), a date or an RFC 3339 time
+//	--grep <regexp>  only entries matching the case-insensitive regular expression
+//	--limit <n>      only the last n entries
+//	--json           print the entries as JSON lines
+func historyCommand(args []string) {
+	var since, grep string
+	var limit int
+	var asJSON bool
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--since", "--grep", "--limit":
+			if i+1 >= len(args) {
+				log.Fatalf("history: %s requires a value", args[i])
+			}
+			switch args[i] {
+			case "--since":
+				since = args[i+1]
+			case "--grep":
+				grep = args[i+1]
+			case "--limit":
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 0 {
+					log.Fatalf("history: invalid --limit %q", args[i+1])
+				}
+				limit = n
+			}
+			i++
+		case "--json":
+			asJSON = true
+		}
+	}
+
+	r, err := config.NewResolver(args)
+	if err != nil {
+		log.Fatalln("history: ", err)
+	}
+	path := r.String(historyFileSetting())
+	if path == "" {
+		log.Fatalln("history: no history file; set VOXINPUT_HISTORY_FILE or --history-file")
+	}
+
+	f, err := historyFilter(since, grep, limit)
+	if err != nil {
+		log.Fatalln("history: ", err)
+	}
+	entries, err := history.Read(path, f)
+	if err != nil {
+		log.Fatalln("history: ", err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				log.Fatalln("history: ", err)
+			}
+		}
+		return
+	}
+	for _, e := range entries {
+		ts := e.Time.Local().Format(time.DateTime)
+		text := strings.ReplaceAll(e.Text, "\n", " ")
+		if e.Kind == history.KindAssistant {
+			fmt.Printf("%s  assistant: %s\n", ts, text)
+		} else {
+			fmt.Printf("%s  %s\n", ts, text)
+		}
+	}
+}
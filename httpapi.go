@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/richiejp/VoxInput/internal/ipc"
+	"github.com/richiejp/VoxInput/internal/pid"
+)
+
+// startHTTPAPI serves the IPC server over HTTP on opts.HTTPAddr. Without a
+// configured token it generates one and writes it to the instance's token
+// file, readable only by the user, whose path is returned so it can be
+// removed on exit.
+func startHTTPAPI(server *ipc.Server, opts listenOptions) (*ipc.HTTPServer, string, error) {
+	token := opts.HTTPToken
+	var tokenPath string
+	if token == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", fmt.Errorf("generate token: %w", err)
+		}
+		token = hex.EncodeToString(buf)
+
+		var err error
+		tokenPath, err = pid.TokenPath(opts.Instance)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(tokenPath), 0755); err != nil {
+			return nil, "", fmt.Errorf("create directory for token: %w", err)
+		}
+		if err := os.WriteFile(tokenPath, []byte(token), 0600); err != nil {
+			return nil, "", fmt.Errorf("write token: %w", err)
+		}
+	}
+
+	h, err := ipc.NewHTTPServer(server, opts.HTTPAddr, token)
+	if err != nil {
+		if tokenPath != "" {
+			os.Remove(tokenPath)
+		}
+		return nil, "", err
+	}
+
+	if tokenPath != "" {
+		log.Printf("main: HTTP API listening on http://%s, token in %s", h.Addr(), tokenPath)
+	} else {
+		log.Printf("main: HTTP API listening on http://%s", h.Addr())
+	}
+	return h, tokenPath, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/ipc"
+	"github.com/richiejp/VoxInput/internal/pid"
+)
+
+// instanceStatusTimeout bounds how long `instances` waits for each
+// listener to answer a status request.
+const instanceStatusTimeout = 2 * time.Second
+
+// instancesCommand implements `voxinput instances`, which lists the
+// listener instances with a PID file and their status.
+func instancesCommand() {
+	instances, err := pid.Instances()
+	if err != nil {
+		log.Fatalln("instances: ", err)
+	}
+	if len(instances) == 0 {
+		fmt.Println("No listeners are running")
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "INSTANCE\tPID\tSTATUS\tMODE\tSOCKET")
+	for _, instance := range instances {
+		name := instance
+		if name == "" {
+			name = "default"
+		}
+		id, status, mode, socket := instanceStatus(instance)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", name, id, status, mode, socket)
+	}
+	if err := tw.Flush(); err != nil {
+		log.Fatalln("instances: ", err)
+	}
+}
+
+// instanceStatus asks the instance's listener for its status over its
+// default socket, or reads its state file if it has no socket. A PID file
+// whose process has gone is reported as stale.
+func instanceStatus(instance string) (id, status, mode, socket string) {
+	id, status, mode, socket = "-", "unknown", "-", "-"
+
+	pidPath, err := pid.Path(instance)
+	if err != nil {
+		return
+	}
+	n, err := pid.Read(pidPath)
+	if err != nil {
+		return
+	}
+	id = fmt.Sprint(n)
+	proc, err := os.FindProcess(n)
+	if err == nil {
+		err = proc.Signal(syscall.Signal(0))
+	}
+	if err != nil {
+		status = "stale"
+		return
+	}
+
+	if path := ipc.SocketPath(instance); fileExists(path) {
+		if client, err := ipc.Connect(path); err == nil {
+			defer client.Close()
+			socket = path
+			if h := client.Hello(); h != nil && h.Capabilities.Mode != "" {
+				mode = h.Capabilities.Mode
+			}
+			if reply, err := client.Request(ipc.Command{Kind: ipc.CommandStatus}, instanceStatusTimeout); err == nil {
+				status = reply.Text
+				return
+			}
+		}
+	}
+
+	statePath, err := pid.StatePath(instance)
+	if err != nil {
+		return
+	}
+	recording, err := pid.ReadState(statePath)
+	if err != nil {
+		return
+	}
+	status = "idle"
+	if recording {
+		status = "recording"
+	}
+	return
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -140,11 +140,15 @@ func Capture(ctx context.Context, w io.Writer, config StreamConfig) error {
 				return
 			}
 
+			metricCallbacks.Inc()
 			if len(inputSamples) > 0 {
+				metricInputBytes.Add(float64(len(inputSamples)))
 				_, err := w.Write(inputSamples)
 				if err != nil {
 					aborted = true
 					abortChan <- err
+				} else {
+					metricWrittenBytes.Add(float64(len(inputSamples)))
 				}
 			}
 		},
@@ -178,9 +182,10 @@ func CaptureToRing(ctx context.Context, ring *Int16Ring, config StreamConfig) er
 	return stream(ctx, abortChan, config, malgo.Capture, deviceCallbacks)
 }
 
-// resampleS16 is an allocating wrapper over resampleS16Into, kept for tests
-// and non-hot-path callers.
-func resampleS16(src []byte, fromRate, toRate int) []byte {
+// ResampleS16 resamples 16-bit mono PCM with linear interpolation. It is an
+// allocating wrapper over resampleS16Into for non-hot-path callers, such as
+// transcribing a file.
+func ResampleS16(src []byte, fromRate, toRate int) []byte {
 	if fromRate == toRate {
 		out := make([]byte, len(src))
 		copy(out, src)
@@ -320,6 +325,7 @@ func Duplex(ctx context.Context, r io.Reader, w io.Writer, config StreamConfig,
 				return
 			}
 			callbackCount++
+			metricCallbacks.Inc()
 
 			// Handle the speaker buffer first so refSamples is ready before
 			// we touch the capture side.
@@ -395,6 +401,7 @@ func Duplex(ctx context.Context, r io.Reader, w io.Writer, config StreamConfig,
 				}
 
 				diagInputBytes += len(inputSamples)
+				metricInputBytes.Add(float64(len(inputSamples)))
 
 				// Fast path: hand mic+ref to the worker via rings and return.
 				if delegateToWorker && len(refSamples) > 0 {
@@ -430,6 +437,7 @@ func Duplex(ctx context.Context, r io.Reader, w io.Writer, config StreamConfig,
 							}
 						} else {
 							diagProcessorNil++
+							metricProcessorEmpty.Inc()
 							samplesToWrite = nil
 						}
 					}
@@ -445,6 +453,7 @@ func Duplex(ctx context.Context, r io.Reader, w io.Writer, config StreamConfig,
 							samplesToWrite = resampledInput[:n]
 						}
 						diagWrittenBytes += len(samplesToWrite)
+						metricWrittenBytes.Add(float64(len(samplesToWrite)))
 						_, err := w.Write(samplesToWrite)
 						if err != nil {
 							aborted = true
@@ -608,6 +617,7 @@ func (w *AECWorker) drain() {
 			log.Printf("AECWorker: write error: %v", err)
 			return
 		}
+		metricWrittenBytes.Add(float64(len(cleaned)))
 	}
 }
 
@@ -14,11 +14,11 @@ func makeS16Bytes(nSamples int) []byte {
 	return b
 }
 
-// --- resampleS16 (byte-level) tests ---
+// --- ResampleS16 (byte-level) tests ---
 
 func TestResampleS16_Identity(t *testing.T) {
 	input := makeS16Bytes(480)
-	got := resampleS16(input, 24000, 24000)
+	got := ResampleS16(input, 24000, 24000)
 	if !bytes.Equal(got, input) {
 		t.Errorf("identity resample changed data: got %d bytes, want %d", len(got), len(input))
 	}
@@ -26,8 +26,8 @@ func TestResampleS16_Identity(t *testing.T) {
 
 func TestResampleS16_RoundTrip(t *testing.T) {
 	input := makeS16Bytes(480)
-	down := resampleS16(input, 24000, 16000)
-	up := resampleS16(down, 16000, 24000)
+	down := ResampleS16(input, 24000, 16000)
+	up := ResampleS16(down, 16000, 24000)
 	inputSamples := len(input) / 2
 	outputSamples := len(up) / 2
 	diff := inputSamples - outputSamples
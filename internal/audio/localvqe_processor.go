@@ -129,10 +129,17 @@ func (p *localvqeProcessor) Process(rec, play, out []byte) int {
 			copy(p.frameOut, p.micBuf[:p.hopLength])
 		}
 
-		p.diagInSum += rmsS16Samples(p.micBuf[:p.hopLength])
-		p.diagRefSum += rmsS16Samples(p.refBuf[:p.hopLength])
-		p.diagOutSum += rmsS16Samples(p.frameOut)
+		inRMS := rmsS16Samples(p.micBuf[:p.hopLength])
+		refRMS := rmsS16Samples(p.refBuf[:p.hopLength])
+		outRMS := rmsS16Samples(p.frameOut)
+		p.diagInSum += inRMS
+		p.diagRefSum += refRMS
+		p.diagOutSum += outRMS
 		p.diagCount++
+		metricAECHops.Inc()
+		metricAECMicRMS.Add(inRMS)
+		metricAECRefRMS.Add(refRMS)
+		metricAECOutRMS.Add(outRMS)
 
 		if outModelLen+p.hopLength > len(p.outModel) {
 			log.Printf("localvqe: outModel overflow")
@@ -184,6 +191,7 @@ func (p *localvqeProcessor) logDiag() {
 	if avgIn > 0 {
 		reductionDB = 20 * math.Log10(avgOut/avgIn)
 	}
+	metricAECReduction.Set(reductionDB)
 	log.Printf("LocalVQE: avgIn=%.0f avgRef=%.0f avgOut=%.0f reduction=%.1fdB hops=%d",
 		avgIn, avgRef, avgOut, reductionDB, p.diagCount)
 	p.diagInSum = 0
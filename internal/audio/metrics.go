@@ -0,0 +1,25 @@
+package audio
+
+import "github.com/richiejp/VoxInput/internal/metrics"
+
+var (
+	metricCallbacks = metrics.NewCounter("voxinput_audio_callbacks_total",
+		"Audio device callbacks of capture and duplex streams.")
+	metricInputBytes = metrics.NewCounter("voxinput_audio_input_bytes_total",
+		"Bytes captured from the microphone.")
+	metricWrittenBytes = metrics.NewCounter("voxinput_audio_written_bytes_total",
+		"Bytes of captured audio passed on for sending, after AEC and resampling.")
+	metricProcessorEmpty = metrics.NewCounter("voxinput_audio_processor_empty_total",
+		"Duplex callbacks in which the inline AEC processor produced no output.")
+
+	metricAECHops = metrics.NewCounter("voxinput_aec_hops_total",
+		"Hops processed by the LocalVQE echo canceller.")
+	metricAECMicRMS = metrics.NewCounter("voxinput_aec_mic_rms_total",
+		"Sum of the RMS of each microphone hop fed to LocalVQE; divide by voxinput_aec_hops_total for the mean.")
+	metricAECRefRMS = metrics.NewCounter("voxinput_aec_ref_rms_total",
+		"Sum of the RMS of each reference hop fed to LocalVQE.")
+	metricAECOutRMS = metrics.NewCounter("voxinput_aec_out_rms_total",
+		"Sum of the RMS of each hop output by LocalVQE.")
+	metricAECReduction = metrics.NewGauge("voxinput_aec_reduction_db",
+		"Echo reduction in dB over the last diagnostic window of 500 hops.")
+)
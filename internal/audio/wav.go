@@ -2,9 +2,24 @@ package audio
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 )
 
+// WAV format codes, as found in the fmt chunk and the sub-format of
+// WAVE_FORMAT_EXTENSIBLE
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavSubFormatSuffix follows the format code in the sub-format GUID of a
+// WAVE_FORMAT_EXTENSIBLE fmt chunk
+var wavSubFormatSuffix = []byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
 // WAVHeader represents the WAV file header (44 bytes for PCM)
 type WAVHeader struct {
 	// RIFF Chunk (12 bytes)
@@ -27,23 +42,44 @@ type WAVHeader struct {
 	Subchunk2Size uint32
 }
 
+// wavHeaderSize is the size of WAVHeader in a file
+const wavHeaderSize = 44
+
+// wavMaxFmtSize limits the fmt chunk read, so a corrupt size cannot make
+// ReadWAV allocate gigabytes. The largest, WAVE_FORMAT_EXTENSIBLE, is 40
+// bytes.
+const wavMaxFmtSize = 1 << 10
+
 func NewWAVHeader(pcmLen uint32, sampleRate uint32) WAVHeader {
+	return NewWAVHeaderFormat(pcmLen, WAVFormat{SampleRate: int(sampleRate), Channels: 1, BitsPerSample: 16})
+}
+
+// NewWAVHeaderFormat returns the header for pcmLen bytes of samples in the
+// given format, e.g. stereo or float32.
+func NewWAVHeaderFormat(pcmLen uint32, format WAVFormat) WAVHeader {
+	audioFormat := uint16(wavFormatPCM)
+	if format.Float {
+		audioFormat = wavFormatFloat
+	}
+	blockAlign := format.Channels * format.BitsPerSample / 8
+
 	header := WAVHeader{
 		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
 		Format:        [4]byte{'W', 'A', 'V', 'E'},
 		Subchunk1ID:   [4]byte{'f', 'm', 't', ' '},
 		Subchunk1Size: 16, // PCM = 16 bytes
-		AudioFormat:   1,  // PCM
-		NumChannels:   1,  // Mono
-		SampleRate:    sampleRate,
-		ByteRate:      sampleRate * 2, // SampleRate * BlockAlign (mono, 2 bytes per sample)
-		BlockAlign:    2,              // 16-bit = 2 bytes per sample
-		BitsPerSample: 16,
+		AudioFormat:   audioFormat,
+		NumChannels:   uint16(format.Channels),
+		SampleRate:    uint32(format.SampleRate),
+		ByteRate:      uint32(format.SampleRate * blockAlign),
+		BlockAlign:    uint16(blockAlign),
+		BitsPerSample: uint16(format.BitsPerSample),
 		Subchunk2ID:   [4]byte{'d', 'a', 't', 'a'},
 		Subchunk2Size: pcmLen,
 	}
 
-	header.ChunkSize = 36 + header.Subchunk2Size
+	// The data chunk is padded to an even size
+	header.ChunkSize = 36 + header.Subchunk2Size + header.Subchunk2Size%2
 
 	return header
 }
@@ -51,3 +87,257 @@ func NewWAVHeader(pcmLen uint32, sampleRate uint32) WAVHeader {
 func (h *WAVHeader) Write(writer io.Writer) error {
 	return binary.Write(writer, binary.LittleEndian, h)
 }
+
+// WAVWriter writes a WAV file whose length is not known in advance, such as
+// a recording. The sizes in the header are filled in by Close.
+type WAVWriter struct {
+	w io.WriteSeeker
+	// n is the number of bytes of samples written
+	n int64
+}
+
+// NewWAVWriter writes a header for the format to w, which the samples are
+// then written after.
+func NewWAVWriter(w io.WriteSeeker, format WAVFormat) (*WAVWriter, error) {
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+	header := NewWAVHeaderFormat(0, format)
+	if err := header.Write(w); err != nil {
+		return nil, fmt.Errorf("wav: write header: %w", err)
+	}
+	return &WAVWriter{w: w}, nil
+}
+
+// Write appends samples to the data chunk.
+func (w *WAVWriter) Write(p []byte) (int, error) {
+	if w.n+int64(len(p)) > math.MaxUint32-wavHeaderSize {
+		return 0, errors.New("wav: data exceeds 4 GiB")
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Close pads the data chunk to an even size and patches the sizes in the
+// header. It closes the underlying writer if it is an io.Closer.
+func (w *WAVWriter) Close() error {
+	err := w.finish()
+	if c, ok := w.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (w *WAVWriter) finish() error {
+	if w.n%2 == 1 {
+		if _, err := w.w.Write([]byte{0}); err != nil {
+			return fmt.Errorf("wav: write padding: %w", err)
+		}
+	}
+	dataSize := uint32(w.n)
+	var size [4]byte
+	for _, field := range []struct {
+		offset int64
+		value  uint32
+	}{{4, 36 + dataSize + dataSize%2}, {40, dataSize}} {
+		if _, err := w.w.Seek(field.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("wav: patch header: %w", err)
+		}
+		binary.LittleEndian.PutUint32(size[:], field.value)
+		if _, err := w.w.Write(size[:]); err != nil {
+			return fmt.Errorf("wav: patch header: %w", err)
+		}
+	}
+	if _, err := w.w.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("wav: patch header: %w", err)
+	}
+	return nil
+}
+
+// WAVFormat describes the samples of a WAV file. Samples are interleaved
+// and little-endian; 8-bit samples are unsigned and other integer samples
+// signed.
+type WAVFormat struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	// Float is set for IEEE float samples
+	Float bool
+}
+
+// validate checks that the format is one ReadWAV and ToS16 support.
+func (f WAVFormat) validate() error {
+	if f.Channels < 1 || f.SampleRate < 1 {
+		return fmt.Errorf("wav: invalid format, %d channels at %d Hz", f.Channels, f.SampleRate)
+	}
+	switch {
+	case f.Float && f.BitsPerSample == 32:
+	case !f.Float && (f.BitsPerSample == 8 || f.BitsPerSample == 16 || f.BitsPerSample == 24 || f.BitsPerSample == 32):
+	case f.Float:
+		return fmt.Errorf("wav: unsupported %d-bit float samples", f.BitsPerSample)
+	default:
+		return fmt.Errorf("wav: unsupported %d-bit samples", f.BitsPerSample)
+	}
+	return nil
+}
+
+// frameSize returns the number of bytes of one sample of every channel.
+func (f WAVFormat) frameSize() int {
+	return f.Channels * f.BitsPerSample / 8
+}
+
+// ToS16 converts samples in a valid format, such as one returned by
+// ReadWAV, to 16-bit signed integers, keeping the channels interleaved.
+// Float samples are clipped to [-1, 1].
+func (f WAVFormat) ToS16(data []byte) []byte {
+	if !f.Float && f.BitsPerSample == 16 {
+		return data[:len(data)/2*2]
+	}
+	size := f.BitsPerSample / 8
+	n := len(data) / size
+	out := make([]byte, n*2)
+	for i := range n {
+		b := data[i*size:]
+		var s int16
+		switch {
+		case f.Float:
+			v := float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			s = int16(max(-1, min(1, v)) * math.MaxInt16)
+		case size == 1:
+			s = int16(int(b[0])-128) << 8
+		case size == 3:
+			s = int16(b[1]) | int16(b[2])<<8
+		case size == 4:
+			s = int16(binary.LittleEndian.Uint32(b) >> 16)
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(s))
+	}
+	return out
+}
+
+// ReadWAV reads a WAV file, returning the format and the contents of its
+// data chunk. It accepts integer and float samples, also in
+// WAVE_FORMAT_EXTENSIBLE files, and skips chunks other than fmt and data.
+// A data chunk whose size was never filled in is read to the end of r: one
+// of 0xFFFFFFFF, or of 0 when the RIFF size shows more data follows.
+func ReadWAV(r io.Reader) (WAVFormat, []byte, error) {
+	var format WAVFormat
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return format, nil, fmt.Errorf("wav: read header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return format, nil, errors.New("wav: not a RIFF WAVE file")
+	}
+
+	riffSize := int64(binary.LittleEndian.Uint32(riff[4:8]))
+	// read counts the bytes of the RIFF chunk read so far
+	read := int64(len("WAVE"))
+
+	var haveFmt bool
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return format, nil, errors.New("wav: no data chunk")
+			}
+			return format, nil, fmt.Errorf("wav: read chunk: %w", err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		read += int64(len(chunk))
+
+		switch id {
+		case "fmt ":
+			if size < 16 || size > wavMaxFmtSize {
+				return format, nil, fmt.Errorf("wav: fmt chunk is %d bytes", size)
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return format, nil, fmt.Errorf("wav: read fmt chunk: %w", err)
+			}
+			var err error
+			if format, err = parseWAVFormat(body); err != nil {
+				return format, nil, err
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return format, nil, errors.New("wav: data chunk before fmt chunk")
+			}
+			var data []byte
+			var err error
+			if size == math.MaxUint32 || (size == 0 && riffSize > read) {
+				data, err = io.ReadAll(r)
+			} else {
+				data, err = io.ReadAll(io.LimitReader(r, size))
+			}
+			if err != nil {
+				return format, nil, fmt.Errorf("wav: read data chunk: %w", err)
+			}
+			// Keep whole frames of a truncated file
+			frame := format.frameSize()
+			return format, data[:len(data)/frame*frame], nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				return format, nil, fmt.Errorf("wav: skip %q chunk: %w", id, err)
+			}
+		}
+		read += size
+		// Chunks are padded to an even size
+		if size%2 == 1 {
+			if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+				return format, nil, fmt.Errorf("wav: read chunk padding: %w", err)
+			}
+			read++
+		}
+	}
+}
+
+// parseWAVFormat parses the body of a fmt chunk.
+func parseWAVFormat(body []byte) (WAVFormat, error) {
+	code := binary.LittleEndian.Uint16(body[0:2])
+	format := WAVFormat{
+		Channels:      int(binary.LittleEndian.Uint16(body[2:4])),
+		SampleRate:    int(binary.LittleEndian.Uint32(body[4:8])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(body[14:16])),
+	}
+	if code == wavFormatExtensible {
+		// The extension holds the valid bits per sample, the channel mask
+		// and the sub-format GUID, which starts with the real format code.
+		// Samples are stored in containers of BitsPerSample, so the valid
+		// bits and the speaker positions are not needed.
+		if len(body) < 40 || string(body[26:40]) != string(wavSubFormatSuffix) {
+			return format, errors.New("wav: invalid WAVE_FORMAT_EXTENSIBLE fmt chunk")
+		}
+		code = binary.LittleEndian.Uint16(body[24:26])
+	}
+	switch code {
+	case wavFormatPCM:
+	case wavFormatFloat:
+		format.Float = true
+	default:
+		return format, fmt.Errorf("wav: unsupported format 0x%04x", code)
+	}
+	return format, format.validate()
+}
+
+// DownmixS16 averages the channels of interleaved 16-bit PCM into mono.
+func DownmixS16(pcm []byte, channels int) []byte {
+	if channels <= 1 {
+		return pcm
+	}
+	frames := len(pcm) / (2 * channels)
+	out := make([]byte, frames*2)
+	for i := range frames {
+		var sum int
+		for c := range channels {
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[(i*channels+c)*2:])))
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(sum/channels)))
+	}
+	return out
+}
@@ -0,0 +1,280 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// riffChunk encodes a chunk with its padding byte.
+func riffChunk(id string, body []byte) []byte {
+	b := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	b = append(b, body...)
+	if len(body)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// wavFile encodes a WAV file with the chunks in order.
+func wavFile(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	return riffChunk("RIFF", body)
+}
+
+// fmtChunk encodes a 16-byte fmt chunk body.
+func fmtChunk(code, channels, rate, bits int) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint16(b, uint16(code))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate*channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*bits/8))
+	return binary.LittleEndian.AppendUint16(b, uint16(bits))
+}
+
+// extensibleFmtChunk encodes a WAVE_FORMAT_EXTENSIBLE fmt chunk body.
+func extensibleFmtChunk(code, channels, rate, bits int) []byte {
+	b := fmtChunk(wavFormatExtensible, channels, rate, bits)
+	b = binary.LittleEndian.AppendUint16(b, 22)
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits))
+	b = binary.LittleEndian.AppendUint32(b, 3) // front left and right
+	b = binary.LittleEndian.AppendUint16(b, uint16(code))
+	return append(b, wavSubFormatSuffix...)
+}
+
+func s16Values(pcm []byte) []int16 {
+	out := make([]int16, len(pcm)/2)
+	for i := range out {
+		out[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+	return out
+}
+
+func TestReadWAV(t *testing.T) {
+	pcm := makeS16Bytes(100)
+	h := NewWAVHeader(uint32(len(pcm)), 16000)
+	var buf bytes.Buffer
+	if err := h.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// Insert a LIST chunk with an odd size, which must be skipped with its
+	// padding byte
+	file := append([]byte{}, buf.Bytes()[:36]...)
+	file = append(file, riffChunk("LIST", []byte("abc"))...)
+	file = append(file, buf.Bytes()[36:]...)
+	file = append(file, pcm...)
+
+	format, data, err := ReadWAV(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != (WAVFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 16}) {
+		t.Errorf("format = %+v", format)
+	}
+	if !bytes.Equal(data, pcm) {
+		t.Errorf("read %d bytes of PCM, want %d", len(data), len(pcm))
+	}
+
+	if _, _, err := ReadWAV(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00AVI "))); err == nil {
+		t.Error("expected an error for a non-WAVE file")
+	}
+}
+
+func TestReadWAVFormats(t *testing.T) {
+	float32s := func(vs ...float32) []byte {
+		var b []byte
+		for _, v := range vs {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+		}
+		return b
+	}
+	for _, tc := range []struct {
+		name   string
+		fmt    []byte
+		data   []byte
+		format WAVFormat
+		want   []int16
+	}{
+		{
+			name:   "8-bit",
+			fmt:    fmtChunk(wavFormatPCM, 1, 8000, 8),
+			data:   []byte{0, 128, 255},
+			format: WAVFormat{SampleRate: 8000, Channels: 1, BitsPerSample: 8},
+			want:   []int16{-32768, 0, 32512},
+		},
+		{
+			name:   "24-bit stereo extensible",
+			fmt:    extensibleFmtChunk(wavFormatPCM, 2, 48000, 24),
+			data:   []byte{0x00, 0x00, 0x80, 0xff, 0xff, 0x7f, 0x00, 0x34, 0x12, 0x00, 0xcc, 0xed},
+			format: WAVFormat{SampleRate: 48000, Channels: 2, BitsPerSample: 24},
+			want:   []int16{-32768, 32767, 0x1234, -0x1234},
+		},
+		{
+			name:   "32-bit",
+			fmt:    fmtChunk(wavFormatPCM, 1, 16000, 32),
+			data:   binary.LittleEndian.AppendUint32(nil, 0x12345678),
+			format: WAVFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 32},
+			want:   []int16{0x1234},
+		},
+		{
+			name:   "float",
+			fmt:    fmtChunk(wavFormatFloat, 1, 44100, 32),
+			data:   float32s(0, 0.5, -1, 2),
+			format: WAVFormat{SampleRate: 44100, Channels: 1, BitsPerSample: 32, Float: true},
+			want:   []int16{0, 16383, -32767, 32767},
+		},
+		{
+			name:   "float extensible",
+			fmt:    extensibleFmtChunk(wavFormatFloat, 2, 44100, 32),
+			data:   float32s(0.25, -0.25),
+			format: WAVFormat{SampleRate: 44100, Channels: 2, BitsPerSample: 32, Float: true},
+			want:   []int16{8191, -8191},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file := wavFile(riffChunk("fmt ", tc.fmt), riffChunk("fact", []byte{1, 0, 0, 0}), riffChunk("data", tc.data))
+			format, data, err := ReadWAV(bytes.NewReader(file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tc.format {
+				t.Errorf("format = %+v, want %+v", format, tc.format)
+			}
+			got := s16Values(format.ToS16(data))
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("samples = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadWAVRejects(t *testing.T) {
+	for name, file := range map[string][]byte{
+		"float64":       wavFile(riffChunk("fmt ", fmtChunk(wavFormatFloat, 1, 8000, 64)), riffChunk("data", nil)),
+		"a-law":         wavFile(riffChunk("fmt ", fmtChunk(6, 1, 8000, 8)), riffChunk("data", nil)),
+		"no channels":   wavFile(riffChunk("fmt ", fmtChunk(wavFormatPCM, 0, 8000, 16)), riffChunk("data", nil)),
+		"data first":    wavFile(riffChunk("data", nil), riffChunk("fmt ", fmtChunk(wavFormatPCM, 1, 8000, 16))),
+		"no data chunk": wavFile(riffChunk("fmt ", fmtChunk(wavFormatPCM, 1, 8000, 16))),
+		"bad extension": wavFile(riffChunk("fmt ", extensibleFmtChunk(wavFormatPCM, 1, 8000, 16)[:30]), riffChunk("data", nil)),
+		"long fmt":      wavFile(riffChunk("fmt ", append(fmtChunk(wavFormatPCM, 1, 8000, 16), make([]byte, 2048)...)), riffChunk("data", nil)),
+		"4 GiB fmt":     append([]byte("RIFF\xff\xff\xff\xffWAVEfmt "), 0xf0, 0xff, 0xff, 0xff),
+	} {
+		if _, _, err := ReadWAV(bytes.NewReader(file)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReadWAVUnknownSize(t *testing.T) {
+	// A streamed file whose sizes were never filled in, with a trailing
+	// incomplete frame
+	file := wavFile(riffChunk("fmt ", fmtChunk(wavFormatPCM, 2, 8000, 16)))
+	file = append(file, "data"...)
+	file = binary.LittleEndian.AppendUint32(file, math.MaxUint32)
+	file = append(file, 1, 0, 2, 0, 3, 0, 4, 0, 5, 0)
+
+	_, data, err := ReadWAV(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s16Values(data); !slices.Equal(got, []int16{1, 2, 3, 4}) {
+		t.Errorf("samples = %v", got)
+	}
+}
+
+func TestReadWAVEmptyData(t *testing.T) {
+	format := fmtChunk(wavFormatPCM, 1, 8000, 16)
+
+	// An empty data chunk followed by a chunk outside the RIFF chunk, such
+	// as an appended ID3 tag, which is not audio
+	file := wavFile(riffChunk("fmt ", format), riffChunk("data", nil))
+	file = append(file, riffChunk("id3 ", []byte("tags"))...)
+	_, data, err := ReadWAV(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("read %d bytes from an empty data chunk", len(data))
+	}
+
+	// The RIFF size was filled in but not the data size
+	pcm := binary.LittleEndian.AppendUint16(nil, 7)
+	file = wavFile(riffChunk("fmt ", format), riffChunk("data", pcm))
+	binary.LittleEndian.PutUint32(file[len(file)-len(pcm)-4:], 0)
+	_, data, err = ReadWAV(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, pcm) {
+		t.Errorf("read %v, want %v", data, pcm)
+	}
+}
+
+func TestWAVWriter(t *testing.T) {
+	for _, format := range []WAVFormat{
+		{SampleRate: 48000, Channels: 2, BitsPerSample: 16},
+		{SampleRate: 8000, Channels: 1, BitsPerSample: 8},
+		{SampleRate: 24000, Channels: 1, BitsPerSample: 32, Float: true},
+	} {
+		path := filepath.Join(t.TempDir(), "out.wav")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewWAVWriter(f, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 8-bit mono gets an odd length, which needs padding
+		frame := format.frameSize()
+		var want []byte
+		for i := range 3 {
+			chunk := bytes.Repeat([]byte{byte(i + 1)}, frame*(i+1))
+			want = append(want, chunk...)
+			if _, err := w.Write(chunk); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if riffSize := binary.LittleEndian.Uint32(file[4:]); int(riffSize) != len(file)-8 {
+			t.Errorf("%+v: RIFF size %d for a %d byte file", format, riffSize, len(file))
+		}
+		got, data, err := ReadWAV(bytes.NewReader(file))
+		if err != nil {
+			t.Fatalf("%+v: %v", format, err)
+		}
+		if got != format || !bytes.Equal(data, want) {
+			t.Errorf("read %+v with %d bytes, want %+v with %d", got, len(data), format, len(want))
+		}
+	}
+
+	if _, err := NewWAVWriter(nil, WAVFormat{SampleRate: 8000, Channels: 1, BitsPerSample: 12}); err == nil {
+		t.Error("expected an error for 12-bit samples")
+	}
+}
+
+func TestDownmixS16(t *testing.T) {
+	var stereo []byte
+	for _, s := range []int16{100, 300, -100, -300, 1000, -1000} {
+		stereo = binary.LittleEndian.AppendUint16(stereo, uint16(s))
+	}
+	mono := DownmixS16(stereo, 2)
+	if got := s16Values(mono); !slices.Equal(got, []int16{200, -200, 0}) {
+		t.Errorf("downmixed = %v", got)
+	}
+}
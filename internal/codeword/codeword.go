@@ -0,0 +1,154 @@
+// Package codeword gates dictation on spoken start and stop phrases, so a
+// realtime session can stay connected while only the speech between e.g.
+// "start dictation" and "stop dictation" is typed.
+package codeword
+
+import (
+	"strings"
+	"unicode"
+)
+
+// word is a word of a transcript with its position, so the text between
+// code words can be cut out with its original punctuation.
+type word struct {
+	norm       string
+	start, end int
+}
+
+// words splits text into runs of letters and digits. Apostrophes inside a
+// word are kept so "don't" stays one word.
+func words(text string) []word {
+	var ws []word
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) ||
+			(r == '\'' || r == '’') && start >= 0
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			ws = append(ws, word{start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ws = append(ws, word{start: start, end: len(text)})
+	}
+	for i := range ws {
+		ws[i].norm = normalize(text[ws[i].start:ws[i].end])
+	}
+	return ws
+}
+
+func normalize(w string) string {
+	return strings.ToLower(strings.TrimRight(w, "'’"))
+}
+
+// phrase returns the normalized words of a code word phrase.
+func phrase(p string) []string {
+	var norms []string
+	for _, w := range words(p) {
+		norms = append(norms, w.norm)
+	}
+	return norms
+}
+
+// Valid reports whether p has a word to match. A phrase without letters
+// or digits, such as "...", never matches.
+func Valid(p string) bool {
+	return len(phrase(p)) > 0
+}
+
+// Gate tracks whether dictation is active. The zero value is not usable;
+// create one with New.
+type Gate struct {
+	start, stop []string
+	active      bool
+}
+
+// New returns an inactive gate for the given start and stop phrases.
+// Matching ignores case and punctuation, so "Start dictation." matches
+// "start dictation".
+func New(start, stop string) *Gate {
+	return &Gate{start: phrase(start), stop: phrase(stop)}
+}
+
+// Active reports whether dictation is on.
+func (g *Gate) Active() bool {
+	return g.active
+}
+
+// Filter returns the parts of text spoken while dictation was active,
+// joined by spaces, with the code words removed, and whether dictation is
+// now on or off when it was not before.
+func (g *Gate) Filter(text string) (string, bool) {
+	ws := words(text)
+	was := g.active
+
+	var parts []string
+	// from is the first word of the current dictated run, -1 if none
+	from := -1
+	flush := func(to int) {
+		if from >= 0 && to > from {
+			parts = append(parts, span(text, ws, from, to))
+		}
+		from = -1
+	}
+
+	for i := 0; i < len(ws); {
+		if !g.active {
+			if matches(ws[i:], g.start) {
+				g.active = true
+				i += len(g.start)
+				continue
+			}
+			i++
+			continue
+		}
+		if matches(ws[i:], g.stop) {
+			flush(i)
+			g.active = false
+			i += len(g.stop)
+			continue
+		}
+		if from < 0 {
+			from = i
+		}
+		i++
+	}
+	if g.active {
+		flush(len(ws))
+	}
+
+	return strings.Join(parts, " "), g.active != was
+}
+
+func matches(ws []word, p []string) bool {
+	if len(p) == 0 || len(ws) < len(p) {
+		return false
+	}
+	for i, norm := range p {
+		if ws[i].norm != norm {
+			return false
+		}
+	}
+	return true
+}
+
+// span returns the text of words [from, to), including punctuation which
+// directly follows the last word, such as a full stop.
+func span(text string, ws []word, from, to int) string {
+	end := ws[to-1].end
+	limit := len(text)
+	if to < len(ws) {
+		limit = ws[to].start
+	}
+	for end < limit {
+		r := rune(text[end])
+		if r == ' ' || r == '\t' || r == '\n' {
+			break
+		}
+		end++
+	}
+	return text[ws[from].start:end]
+}
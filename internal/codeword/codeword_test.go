@@ -0,0 +1,76 @@
+package codeword
+
+import "testing"
+
+func TestGateFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		active     bool
+		text       string
+		want       string
+		wantActive bool
+		changed    bool
+	}{
+		{"inactive drops text", false, "hello there", "", false, false},
+		{"start", false, "Start dictation.", "", true, true},
+		{"start mid sentence", false, "OK, start dictation, dear diary.", "dear diary.", true, true},
+		{"active keeps text", true, "It's a nice day.", "It's a nice day.", true, false},
+		{"stop", true, "The end. Stop dictation!", "The end.", false, true},
+		{"start and stop", false, "start dictation hello world stop dictation", "hello world", false, false},
+		{"stop and start", true, "one. Stop dictation. Ignored. Start dictation. two.", "one. two.", true, false},
+		{"case and punctuation", false, "START, Dictation... go", "go", true, true},
+		{"partial phrase", false, "start the dictation", "", false, false},
+		{"repeated start", true, "start dictation again", "start dictation again", true, false},
+		{"empty", true, "", "", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New("start dictation", "stop dictation")
+			g.active = tt.active
+
+			got, changed := g.Filter(tt.text)
+			if got != tt.want {
+				t.Errorf("Filter(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if changed != tt.changed {
+				t.Errorf("Filter(%q) changed = %v, want %v", tt.text, changed, tt.changed)
+			}
+			if g.Active() != tt.wantActive {
+				t.Errorf("Active() = %v, want %v", g.Active(), tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	for p, want := range map[string]bool{
+		"start dictation": true,
+		"Go!":             true,
+		"42":              true,
+		"...":             false,
+		" - ":             false,
+		"":                false,
+	} {
+		if got := Valid(p); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestGateSingleWordPhrases(t *testing.T) {
+	g := New("Begin!", "over")
+
+	if got, _ := g.Filter("chatter"); got != "" {
+		t.Errorf("got %q before start", got)
+	}
+	if got, changed := g.Filter("begin: the plan"); got != "the plan" || !changed {
+		t.Errorf("got %q, %v, want %q, true", got, changed, "the plan")
+	}
+	if got, _ := g.Filter("don't stop"); got != "don't stop" {
+		t.Errorf("got %q, want %q", got, "don't stop")
+	}
+	if got, changed := g.Filter("Over."); got != "" || !changed || g.Active() {
+		t.Errorf("got %q, %v, active %v after stop", got, changed, g.Active())
+	}
+}
@@ -0,0 +1,102 @@
+// Package config loads the optional VoxInput configuration file and resolves
+// settings from command line flags, environment variables and named profiles.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// File is the on-disk configuration. Profile names the profile used when
+// none is requested with --profile or VOXINPUT_PROFILE.
+//
+//	{
+//	  "profile": "coding",
+//	  "profiles": {
+//	    "coding":  {"lang": "en", "prompt": "Go, goroutine, VoxInput"},
+//	    "meeting": {"capture_device": "Monitor of Built-in Audio", "output_file": "meeting.txt"}
+//	  }
+//	}
+type File struct {
+	Profile  string             `json:"profile,omitempty"`
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// Profile maps setting keys to values. Keys are the environment variable
+// names without the VOXINPUT_ prefix in lower case, e.g. "transcription_model"
+// for VOXINPUT_TRANSCRIPTION_MODEL.
+type Profile map[string]string
+
+// UnmarshalJSON accepts strings, numbers and booleans as values so profiles
+// can be written naturally. Booleans become "yes" or "no".
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	out := make(Profile, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			out[k] = v
+		case json.Number:
+			out[k] = v.String()
+		case bool:
+			if v {
+				out[k] = "yes"
+			} else {
+				out[k] = "no"
+			}
+		case nil:
+			out[k] = ""
+		default:
+			return fmt.Errorf("setting %q: expected a string, number or boolean", k)
+		}
+	}
+	*p = out
+
+	return nil
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/voxinput/config.json or the platform
+// equivalent.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("config: cannot determine config directory: %w", err)
+	}
+	return filepath.Join(dir, "voxinput", "config.json"), nil
+}
+
+// Load reads and parses the configuration file at path.
+func Load(path string) (*File, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to read file: %s: %w", path, err)
+	}
+
+	var f File
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return nil, fmt.Errorf("config: failed to parse file: %s: %w", path, err)
+	}
+
+	return &f, nil
+}
+
+// ProfileNames returns the names of the profiles defined in f, sorted.
+func (f *File) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
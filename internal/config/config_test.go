@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadProfiles(t *testing.T) {
+	path := writeConfig(t, `{
+		"profile": "coding",
+		"profiles": {
+			"coding": {"lang": "en", "input_sample_rate": 16000, "enable_aec": false},
+			"meeting": {"output_file": "meeting.txt", "show_status": true}
+		}
+	}`)
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if f.Profile != "coding" {
+		t.Errorf("default profile = %q, want coding", f.Profile)
+	}
+	coding := f.Profiles["coding"]
+	if coding["lang"] != "en" {
+		t.Errorf("lang = %q, want en", coding["lang"])
+	}
+	if coding["input_sample_rate"] != "16000" {
+		t.Errorf("input_sample_rate = %q, want 16000", coding["input_sample_rate"])
+	}
+	if coding["enable_aec"] != "no" {
+		t.Errorf("enable_aec = %q, want no", coding["enable_aec"])
+	}
+	if f.Profiles["meeting"]["show_status"] != "yes" {
+		t.Errorf("show_status = %q, want yes", f.Profiles["meeting"]["show_status"])
+	}
+
+	names := f.ProfileNames()
+	if len(names) != 2 || names[0] != "coding" || names[1] != "meeting" {
+		t.Errorf("ProfileNames = %v, want [coding meeting]", names)
+	}
+}
+
+func TestLoadRejectsNestedValues(t *testing.T) {
+	path := writeConfig(t, `{"profiles": {"bad": {"lang": ["en"]}}}`)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for array value")
+	}
+}
+
+func TestLoadInvalidJSON(t *testing.T) {
+	path := writeConfig(t, `{"profiles":`)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for truncated file")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "nonexistent.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
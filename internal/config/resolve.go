@@ -0,0 +1,257 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SourceKind says which layer a resolved value came from.
+type SourceKind string
+
+const (
+	SourceFlag    SourceKind = "flag"
+	SourceEnv     SourceKind = "env"
+	SourceProfile SourceKind = "profile"
+	SourceDefault SourceKind = "default"
+)
+
+// Source records where a resolved value came from. Name is the flag, the
+// environment variable or the profile name; it is empty for defaults.
+type Source struct {
+	Kind SourceKind
+	Name string
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return string(s.Kind)
+	}
+	return string(s.Kind) + " " + s.Name
+}
+
+// Setting describes one configurable value and the places it may be set.
+type Setting struct {
+	// Key is the name used in profiles.
+	Key string
+	// Env lists environment variables checked in order, the first
+	// non-empty one wins.
+	Env []string
+	// Flag takes its value from the following argument, e.g. "--prompt".
+	Flag string
+	// On and Off are switches setting the value to "yes" or "no". Off wins
+	// when both are given.
+	On  string
+	Off string
+	// Default is used when the setting is not found anywhere else.
+	Default string
+	// Secret values are redacted when displayed.
+	Secret bool
+}
+
+// Value is a resolved setting. Note explains adjustments made after lookup,
+// such as a fallback after a parse failure.
+type Value struct {
+	Key    string
+	Value  string
+	Source Source
+	Note   string
+	Secret bool
+}
+
+// Resolver looks settings up with the precedence flag > env > profile >
+// default and remembers where each value came from.
+type Resolver struct {
+	args        []string
+	path        string
+	profileName string
+	profile     Profile
+	values      []Value
+	used        map[string]bool
+}
+
+// NewResolver loads the configuration file named by --config, VOXINPUT_CONFIG
+// or DefaultPath, in that order, and selects the profile named by --profile,
+// VOXINPUT_PROFILE or the file's default profile. A missing file is only an
+// error when it was named explicitly.
+func NewResolver(args []string) (*Resolver, error) {
+	path, explicit := FlagValue(args, "--config")
+	if !explicit {
+		path = os.Getenv("VOXINPUT_CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		var err error
+		path, err = DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := &Resolver{
+		args: args,
+		used: make(map[string]bool),
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		if explicit || !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		f = &File{}
+	} else {
+		r.path = path
+	}
+
+	name, ok := FlagValue(args, "--profile")
+	if !ok {
+		name = os.Getenv("VOXINPUT_PROFILE")
+	}
+	if name == "" {
+		name = f.Profile
+	}
+	if name != "" {
+		profile, ok := f.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("config: profile %q not found in %s (available: %s)",
+				name, path, strings.Join(f.ProfileNames(), ", "))
+		}
+		r.profileName = name
+		r.profile = profile
+	}
+
+	return r, nil
+}
+
+// Path returns the configuration file in use, or "" when there is none.
+func (r *Resolver) Path() string {
+	return r.path
+}
+
+// ProfileName returns the selected profile, or "" when there is none.
+func (r *Resolver) ProfileName() string {
+	return r.profileName
+}
+
+// String resolves s and records the result.
+func (r *Resolver) String(s Setting) string {
+	val, src := r.lookup(s)
+	r.Set(s.Key, val, src)
+	if s.Secret {
+		r.values[r.index(s.Key)].Secret = true
+	}
+	return val
+}
+
+// Bool resolves s as a yes/no switch. Anything other than "no" or "false"
+// counts as yes.
+func (r *Resolver) Bool(s Setting) bool {
+	val := r.String(s)
+	return !(val == "no" || val == "false")
+}
+
+func (r *Resolver) lookup(s Setting) (string, Source) {
+	if s.Off != "" && HasFlag(r.args, s.Off) {
+		return "no", Source{Kind: SourceFlag, Name: s.Off}
+	}
+	if s.On != "" && HasFlag(r.args, s.On) {
+		return "yes", Source{Kind: SourceFlag, Name: s.On}
+	}
+	if s.Flag != "" {
+		if val, ok := FlagValue(r.args, s.Flag); ok && val != "" {
+			return val, Source{Kind: SourceFlag, Name: s.Flag}
+		}
+	}
+
+	for _, name := range s.Env {
+		if val := os.Getenv(name); val != "" {
+			return val, Source{Kind: SourceEnv, Name: name}
+		}
+	}
+
+	if val, ok := r.profile[s.Key]; ok {
+		return val, Source{Kind: SourceProfile, Name: r.profileName}
+	}
+
+	return s.Default, Source{Kind: SourceDefault}
+}
+
+// Set records val as the value of key, replacing any earlier result. It is
+// for values that are derived or adjusted after resolution.
+func (r *Resolver) Set(key, val string, src Source) {
+	r.used[key] = true
+	if i := r.index(key); i >= 0 {
+		r.values[i].Value = val
+		r.values[i].Source = src
+		return
+	}
+	r.values = append(r.values, Value{Key: key, Value: val, Source: src})
+}
+
+// Note attaches an explanation to the resolved value of key, e.g. when the
+// caller fell back to a default because the value did not parse.
+func (r *Resolver) Note(key, val, note string) {
+	if i := r.index(key); i >= 0 {
+		r.values[i].Value = val
+		r.values[i].Note = note
+	}
+}
+
+// Source returns where key was resolved from.
+func (r *Resolver) Source(key string) Source {
+	if i := r.index(key); i >= 0 {
+		return r.values[i].Source
+	}
+	return Source{}
+}
+
+func (r *Resolver) index(key string) int {
+	for i, v := range r.values {
+		if v.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Values returns every resolved setting in the order it was looked up.
+func (r *Resolver) Values() []Value {
+	out := make([]Value, len(r.values))
+	copy(out, r.values)
+	return out
+}
+
+// Unused returns the keys in the selected profile that no setting asked
+// for, which are most likely typos.
+func (r *Resolver) Unused() []string {
+	var keys []string
+	for k := range r.profile {
+		if !r.used[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FlagValue returns the argument following the first occurrence of name.
+func FlagValue(args []string, name string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		if args[i] == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// HasFlag reports whether name appears in args.
+func HasFlag(args []string, name string) bool {
+	for _, a := range args {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const testProfiles = `{
+	"profile": "coding",
+	"profiles": {
+		"coding": {"lang": "en", "prompt": "goroutine", "mode": "transcription"},
+		"meeting": {"lang": "de", "show_status": "no", "typo_key": "x"}
+	}
+}`
+
+func TestResolverPrecedence(t *testing.T) {
+	t.Setenv("VOXINPUT_CONFIG", writeConfig(t, testProfiles))
+	t.Setenv("VOXINPUT_PROFILE", "")
+	t.Setenv("VOXINPUT_PROMPT", "from env")
+	t.Setenv("VOXINPUT_MODE", "")
+
+	r, err := NewResolver([]string{"--mode", "assistant"})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	if r.ProfileName() != "coding" {
+		t.Fatalf("profile = %q, want coding", r.ProfileName())
+	}
+
+	tests := []struct {
+		setting Setting
+		want    string
+		source  Source
+	}{
+		{Setting{Key: "mode", Env: []string{"VOXINPUT_MODE"}, Flag: "--mode", Default: "transcription"},
+			"assistant", Source{Kind: SourceFlag, Name: "--mode"}},
+		{Setting{Key: "prompt", Env: []string{"VOXINPUT_PROMPT"}, Flag: "--prompt"},
+			"from env", Source{Kind: SourceEnv, Name: "VOXINPUT_PROMPT"}},
+		{Setting{Key: "lang", Env: []string{"VOXINPUT_LANG_UNSET_FOR_TEST"}},
+			"en", Source{Kind: SourceProfile, Name: "coding"}},
+		{Setting{Key: "socket", Default: "/tmp/x.sock"},
+			"/tmp/x.sock", Source{Kind: SourceDefault}},
+	}
+
+	for _, tt := range tests {
+		got := r.String(tt.setting)
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.setting.Key, got, tt.want)
+		}
+		if src := r.Source(tt.setting.Key); src != tt.source {
+			t.Errorf("%s source = %v, want %v", tt.setting.Key, src, tt.source)
+		}
+	}
+
+	values := r.Values()
+	if len(values) != len(tests) || values[0].Key != "mode" {
+		t.Errorf("Values() = %+v, want %d values in lookup order", values, len(tests))
+	}
+}
+
+func TestResolverBoolSwitches(t *testing.T) {
+	t.Setenv("VOXINPUT_CONFIG", writeConfig(t, testProfiles))
+	t.Setenv("VOXINPUT_AEC_NOISE_GATE", "yes")
+
+	setting := Setting{
+		Key:     "aec_noise_gate",
+		Env:     []string{"VOXINPUT_AEC_NOISE_GATE"},
+		On:      "--aec-noise-gate",
+		Off:     "--no-aec-noise-gate",
+		Default: "no",
+	}
+
+	r, err := NewResolver(nil)
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	if !r.Bool(setting) {
+		t.Error("expected env to enable the switch")
+	}
+
+	r, err = NewResolver([]string{"--aec-noise-gate", "--no-aec-noise-gate"})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	if r.Bool(setting) {
+		t.Error("expected --no-aec-noise-gate to win")
+	}
+	if src := r.Source("aec_noise_gate"); src.Name != "--no-aec-noise-gate" {
+		t.Errorf("source = %v, want flag --no-aec-noise-gate", src)
+	}
+}
+
+func TestResolverProfileSelection(t *testing.T) {
+	t.Setenv("VOXINPUT_CONFIG", writeConfig(t, testProfiles))
+	t.Setenv("VOXINPUT_PROFILE", "coding")
+
+	r, err := NewResolver([]string{"--profile", "meeting"})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	if r.ProfileName() != "meeting" {
+		t.Errorf("profile = %q, want meeting (flag beats env)", r.ProfileName())
+	}
+	if r.Bool(Setting{Key: "show_status", Default: "yes"}) {
+		t.Error("expected meeting profile to disable show_status")
+	}
+	r.String(Setting{Key: "lang"})
+
+	unused := r.Unused()
+	if len(unused) != 1 || unused[0] != "typo_key" {
+		t.Errorf("Unused() = %v, want [typo_key]", unused)
+	}
+
+	if _, err := NewResolver([]string{"--profile", "nope"}); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestResolverConfigFlag(t *testing.T) {
+	t.Setenv("VOXINPUT_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := NewResolver(nil); err == nil {
+		t.Error("expected error for explicitly named missing file")
+	}
+
+	path := writeConfig(t, testProfiles)
+	r, err := NewResolver([]string{"--config", path})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	if r.Path() != path {
+		t.Errorf("Path() = %q, want %q", r.Path(), path)
+	}
+}
+
+func TestResolverWithoutConfigFile(t *testing.T) {
+	t.Setenv("VOXINPUT_CONFIG", "")
+	t.Setenv("VOXINPUT_PROFILE", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	r, err := NewResolver(nil)
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	if r.Path() != "" || r.ProfileName() != "" {
+		t.Errorf("expected no config file or profile, got %q/%q", r.Path(), r.ProfileName())
+	}
+	if got := r.String(Setting{Key: "mode", Default: "transcription"}); got != "transcription" {
+		t.Errorf("mode = %q, want transcription", got)
+	}
+}
+
+func TestFlagValue(t *testing.T) {
+	args := []string{"--replay", "--prompt", "hello world", "--socket"}
+
+	if v, ok := FlagValue(args, "--prompt"); !ok || v != "hello world" {
+		t.Errorf("FlagValue(--prompt) = %q, %v", v, ok)
+	}
+	if _, ok := FlagValue(args, "--socket"); ok {
+		t.Error("expected trailing flag without value to be ignored")
+	}
+	if !HasFlag(args, "--replay") || HasFlag(args, "--no-realtime") {
+		t.Error("HasFlag mismatch")
+	}
+}
+
+func TestResolverNotesAndSecrets(t *testing.T) {
+	t.Setenv("VOXINPUT_CONFIG", writeConfig(t, testProfiles))
+	t.Setenv("VOXINPUT_API_KEY", "sk-secret")
+	t.Setenv("VOXINPUT_TRANSCRIPTION_TIMEOUT", "soon")
+
+	r, err := NewResolver(nil)
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+
+	r.String(Setting{Key: "api_key", Env: []string{"VOXINPUT_API_KEY"}, Secret: true})
+	r.String(Setting{Key: "transcription_timeout", Env: []string{"VOXINPUT_TRANSCRIPTION_TIMEOUT"}, Default: "30s"})
+	r.Note("transcription_timeout", "30s", `invalid value "soon"`)
+
+	values := r.Values()
+	if !values[0].Secret {
+		t.Error("expected api_key to be marked secret")
+	}
+	if values[1].Value != "30s" || values[1].Note == "" {
+		t.Errorf("timeout = %+v, want 30s with a note", values[1])
+	}
+	if values[1].Source.Name != "VOXINPUT_TRANSCRIPTION_TIMEOUT" {
+		t.Errorf("Note should keep the source, got %v", values[1].Source)
+	}
+}
@@ -0,0 +1,114 @@
+// Package correction asks a chat model to fix transcripts, such as jargon
+// the speech recognition model consistently gets wrong. It is given a
+// glossary of the terms to expect and the previous transcripts as context.
+package correction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultContext is how many previous transcripts are sent along with the
+// one being corrected.
+const DefaultContext = 5
+
+const systemPrompt = `You correct the output of a speech recognition model before it is typed into the user's application. Fix words which were misheard, in particular terms from the glossary, and obvious punctuation mistakes. Do not rephrase, translate, summarise or answer the text, and do not add anything. If nothing needs fixing, repeat the transcript exactly. Reply with the corrected transcript only.`
+
+// ChatClient is the part of *openai.Client the corrector uses.
+type ChatClient interface {
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+}
+
+type Config struct {
+	Model    string
+	Glossary []string
+	// Timeout bounds each request; the raw transcript is used after it.
+	Timeout time.Duration
+	// Context is the number of previous transcripts to send, DefaultContext
+	// if zero.
+	Context int
+}
+
+// Corrector corrects the transcripts of one session. It is not safe for
+// concurrent use.
+type Corrector struct {
+	client  ChatClient
+	config  Config
+	history []string
+}
+
+func New(client ChatClient, config Config) *Corrector {
+	if config.Context == 0 {
+		config.Context = DefaultContext
+	}
+	return &Corrector{client: client, config: config}
+}
+
+// prompt returns the system prompt with the glossary and recent
+// transcripts.
+func (c *Corrector) prompt() string {
+	var b strings.Builder
+	b.WriteString(systemPrompt)
+	if len(c.config.Glossary) > 0 {
+		b.WriteString("\n\nGlossary: ")
+		b.WriteString(strings.Join(c.config.Glossary, ", "))
+	}
+	if len(c.history) > 0 {
+		b.WriteString("\n\nThe previous transcripts, for context only:\n")
+		for _, h := range c.history {
+			b.WriteString(h)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Correct returns the corrected transcript. If the model fails, times out
+// or returns nothing, it returns text unchanged along with the error.
+func (c *Corrector) Correct(ctx context.Context, text string) (string, error) {
+	corrected, err := c.correct(ctx, text)
+	if err != nil {
+		corrected = text
+	}
+	c.remember(corrected)
+	return corrected, err
+}
+
+func (c *Corrector) correct(ctx context.Context, text string) (string, error) {
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: c.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: c.prompt()},
+			{Role: openai.ChatMessageRoleUser, Content: text},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("correction: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("correction: no choices in response")
+	}
+	corrected := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if corrected == "" {
+		return "", errors.New("correction: empty response")
+	}
+	return corrected, nil
+}
+
+func (c *Corrector) remember(text string) {
+	c.history = append(c.history, text)
+	if len(c.history) > c.config.Context {
+		c.history = c.history[len(c.history)-c.config.Context:]
+	}
+}
@@ -0,0 +1,106 @@
+package correction
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type fakeClient struct {
+	reply string
+	err   error
+	delay time.Duration
+	reqs  []openai.ChatCompletionRequest
+}
+
+func (f *fakeClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	f.reqs = append(f.reqs, req)
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return openai.ChatCompletionResponse{}, ctx.Err()
+		}
+	}
+	if f.err != nil {
+		return openai.ChatCompletionResponse{}, f.err
+	}
+	return openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{
+		{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: f.reply}},
+	}}, nil
+}
+
+func TestCorrect(t *testing.T) {
+	client := &fakeClient{reply: " Run it on LocalAI.\n"}
+	c := New(client, Config{Model: "small", Glossary: []string{"LocalAI", "VoxInput"}})
+
+	got, err := c.Correct(context.Background(), "run it on local eye")
+	if err != nil {
+		t.Fatalf("Correct: %v", err)
+	}
+	if got != "Run it on LocalAI." {
+		t.Errorf("Correct = %q", got)
+	}
+
+	req := client.reqs[0]
+	if req.Model != "small" {
+		t.Errorf("model = %q", req.Model)
+	}
+	if len(req.Messages) != 2 || req.Messages[1].Content != "run it on local eye" {
+		t.Fatalf("messages = %+v", req.Messages)
+	}
+	if !strings.Contains(req.Messages[0].Content, "Glossary: LocalAI, VoxInput") {
+		t.Errorf("system prompt has no glossary: %q", req.Messages[0].Content)
+	}
+}
+
+func TestCorrectContext(t *testing.T) {
+	client := &fakeClient{reply: "ok"}
+	c := New(client, Config{Context: 2})
+
+	for _, text := range []string{"one", "two", "three"} {
+		client.reply = strings.ToUpper(text)
+		if _, err := c.Correct(context.Background(), text); err != nil {
+			t.Fatalf("Correct(%q): %v", text, err)
+		}
+	}
+
+	prompt := client.reqs[2].Messages[0].Content
+	if strings.Contains(prompt, "Glossary") {
+		t.Error("prompt has a glossary section without a glossary")
+	}
+	if !strings.Contains(prompt, "ONE\nTWO\n") {
+		t.Errorf("prompt lacks the corrected history: %q", prompt)
+	}
+	if got := c.history; len(got) != 2 || got[0] != "TWO" || got[1] != "THREE" {
+		t.Errorf("history = %q, want the last 2 transcripts", got)
+	}
+}
+
+func TestCorrectFallback(t *testing.T) {
+	tests := map[string]*fakeClient{
+		"error":   {err: errors.New("connection refused")},
+		"timeout": {reply: "late", delay: time.Second},
+		"empty":   {reply: "  "},
+	}
+
+	for name, client := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := New(client, Config{Timeout: 20 * time.Millisecond})
+			got, err := c.Correct(context.Background(), "raw text")
+			if err == nil {
+				t.Error("expected an error")
+			}
+			if got != "raw text" {
+				t.Errorf("Correct = %q, want the raw text", got)
+			}
+			if len(c.history) != 1 || c.history[0] != "raw text" {
+				t.Errorf("history = %q, want the raw text", c.history)
+			}
+		})
+	}
+}
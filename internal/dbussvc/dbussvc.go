@@ -0,0 +1,187 @@
+// Package dbussvc exposes the listener on the D-Bus session bus, so desktop
+// environments can bind hotkeys to method calls instead of signals.
+package dbussvc
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"github.com/richiejp/VoxInput/internal/gui"
+	"github.com/richiejp/VoxInput/internal/ipc"
+)
+
+const (
+	// Interface is the D-Bus interface of the daemon object and also the
+	// bus name of the default instance.
+	Interface = "org.voxinput.Daemon"
+	// Path is the object path of the daemon object.
+	Path dbus.ObjectPath = "/org/voxinput/Daemon"
+	// ErrorFailed is the D-Bus error name returned when the listener
+	// rejects a command.
+	ErrorFailed = Interface + ".Error.Failed"
+)
+
+const introspectXML = `
+<node>
+	<interface name="` + Interface + `">
+		<method name="Record">
+			<arg name="status" type="s" direction="out"/>
+		</method>
+		<method name="Stop">
+			<arg name="status" type="s" direction="out"/>
+		</method>
+		<method name="Toggle">
+			<arg name="status" type="s" direction="out"/>
+		</method>
+		<method name="Status">
+			<arg name="status" type="s" direction="out"/>
+		</method>
+		<signal name="StateChanged">
+			<arg name="state" type="s"/>
+			<arg name="text" type="s"/>
+		</signal>
+		<signal name="Transcript">
+			<arg name="text" type="s"/>
+			<arg name="is_user" type="b"/>
+		</signal>
+	</interface>` + introspect.IntrospectDataString + `</node>`
+
+// BusName returns the well-known bus name of the named listener instance,
+// or of the default instance if instance is empty. Characters which are
+// not allowed in bus names are replaced with '_'.
+func BusName(instance string) string {
+	if instance == "" {
+		return Interface
+	}
+	elem := []byte(instance)
+	for i, c := range elem {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			elem[i] = '_'
+		}
+	}
+	if elem[0] >= '0' && elem[0] <= '9' {
+		elem = append([]byte{'_'}, elem...)
+	}
+	return Interface + "." + string(elem)
+}
+
+// Service is the daemon object. Method calls are turned into ipc.Commands
+// for the listen loop, and the gui.Msg stream is emitted as signals.
+type Service struct {
+	conn  *dbus.Conn
+	name  string
+	cmdCh chan ipc.Command
+}
+
+// ConnectSession connects to the session bus and exports the service there.
+func ConnectSession(instance string) (*Service, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect to session bus: %w", err)
+	}
+	s, err := New(conn, instance)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// New exports the service on conn and takes the bus name of the instance.
+// It fails if another process already owns the name.
+func New(conn *dbus.Conn, instance string) (*Service, error) {
+	s := &Service{
+		conn:  conn,
+		name:  BusName(instance),
+		cmdCh: make(chan ipc.Command, 16),
+	}
+
+	if err := conn.Export(daemon{s}, Path, Interface); err != nil {
+		return nil, fmt.Errorf("export %s: %w", Path, err)
+	}
+	if err := conn.Export(introspect.Introspectable(introspectXML), Path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return nil, fmt.Errorf("export introspection: %w", err)
+	}
+
+	reply, err := conn.RequestName(s.name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, fmt.Errorf("request name %s: %w", s.name, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, fmt.Errorf("name %s is already taken", s.name)
+	}
+
+	return s, nil
+}
+
+// Name returns the bus name owned by the service.
+func (s *Service) Name() string {
+	return s.name
+}
+
+// Commands returns the commands received as method calls.
+func (s *Service) Commands() <-chan ipc.Command {
+	return s.cmdCh
+}
+
+// Send emits msg as a StateChanged or Transcript signal.
+func (s *Service) Send(msg gui.Msg) {
+	e := ipc.EventFromGUIMsg(msg)
+
+	var err error
+	switch e.Kind {
+	case ipc.EventStatus, ipc.EventFunctionCall:
+		err = s.conn.Emit(Path, Interface+".StateChanged", string(e.State), e.Text)
+	case ipc.EventTranscript:
+		err = s.conn.Emit(Path, Interface+".Transcript", e.Text, e.IsUser)
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("dbussvc: emit error: %v", err)
+	}
+}
+
+// Close releases the bus name and closes the connection.
+func (s *Service) Close() error {
+	if _, err := s.conn.ReleaseName(s.name); err != nil {
+		log.Printf("dbussvc: release name error: %v", err)
+	}
+	return s.conn.Close()
+}
+
+// request passes a command to the listen loop and waits for its reply.
+func (s *Service) request(kind ipc.CommandKind) (string, *dbus.Error) {
+	replies := make(chan ipc.Event, 1)
+	cmd := ipc.Command{Kind: kind}.WithReply(func(e ipc.Event) { replies <- e })
+
+	select {
+	case s.cmdCh <- cmd:
+	default:
+		return "", dbus.NewError(ErrorFailed, []any{fmt.Sprintf("%s: daemon is busy, command queue is full", kind)})
+	}
+
+	select {
+	case e := <-replies:
+		if !e.OK {
+			return e.Text, dbus.NewError(ErrorFailed, []any{e.Error})
+		}
+		return e.Text, nil
+	case <-time.After(ipc.RequestTimeout):
+		return "", dbus.NewError(ErrorFailed, []any{fmt.Sprintf("%s: timed out waiting for the listener", kind)})
+	}
+}
+
+// daemon holds the exported methods, keeping them off Service's API.
+type daemon struct {
+	s *Service
+}
+
+func (d daemon) Record() (string, *dbus.Error) { return d.s.request(ipc.CommandRecord) }
+func (d daemon) Stop() (string, *dbus.Error)   { return d.s.request(ipc.CommandStop) }
+func (d daemon) Toggle() (string, *dbus.Error) { return d.s.request(ipc.CommandToggle) }
+func (d daemon) Status() (string, *dbus.Error) { return d.s.request(ipc.CommandStatus) }
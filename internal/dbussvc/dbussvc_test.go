@@ -0,0 +1,189 @@
+package dbussvc
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/richiejp/VoxInput/internal/gui"
+	"github.com/richiejp/VoxInput/internal/ipc"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>`
+
+// privateBus starts a dbus-daemon for the test and returns its address.
+func privateBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(conf, []byte(strings.Replace(busConfig, "%s", dir, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+conf, "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read bus address: %v", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestBusName(t *testing.T) {
+	tests := map[string]string{
+		"":          "org.voxinput.Daemon",
+		"assistant": "org.voxinput.Daemon.assistant",
+		"dict-2.a":  "org.voxinput.Daemon.dict_2_a",
+		"2nd":       "org.voxinput.Daemon._2nd",
+	}
+	for instance, want := range tests {
+		if got := BusName(instance); got != want {
+			t.Errorf("BusName(%q) = %q, want %q", instance, got, want)
+		}
+	}
+}
+
+func TestServiceMethods(t *testing.T) {
+	addr := privateBus(t)
+
+	s, err := New(connect(t, addr), "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Answer like the listen loop
+	recording := false
+	go func() {
+		for cmd := range s.Commands() {
+			switch cmd.Kind {
+			case ipc.CommandRecord:
+				recording = true
+			case ipc.CommandStop:
+				cmd.ReplyError(errors.New("not recording"))
+				continue
+			}
+			text := "idle"
+			if recording {
+				text = "recording"
+			}
+			cmd.Reply(ipc.Event{Text: text})
+		}
+	}()
+
+	obj := connect(t, addr).Object(Interface, Path)
+
+	var status string
+	if err := obj.Call(Interface+".Status", 0).Store(&status); err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status != "idle" {
+		t.Errorf("Status = %q, want idle", status)
+	}
+
+	if err := obj.Call(Interface+".Record", 0).Store(&status); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if status != "recording" {
+		t.Errorf("Record = %q, want recording", status)
+	}
+
+	err = obj.Call(Interface+".Stop", 0).Err
+	var dbusErr dbus.Error
+	if !errors.As(err, &dbusErr) || dbusErr.Name != ErrorFailed {
+		t.Errorf("Stop error = %v, want %s", err, ErrorFailed)
+	}
+
+	// A second service cannot take the same name
+	if _, err := New(connect(t, addr), ""); err == nil {
+		t.Error("expected error for duplicate bus name")
+	}
+}
+
+func TestServiceSignals(t *testing.T) {
+	addr := privateBus(t)
+
+	s, err := New(connect(t, addr), "assistant")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	client := connect(t, addr)
+	if err := client.AddMatchSignal(dbus.WithMatchInterface(Interface)); err != nil {
+		t.Fatalf("AddMatchSignal: %v", err)
+	}
+	signals := make(chan *dbus.Signal, 10)
+	client.Signal(signals)
+
+	s.Send(&gui.ShowSpeechDetectedMsg{})
+	s.Send(&gui.ShowTranscriptMsg{Text: "hello", IsUser: true})
+
+	want := []struct {
+		name string
+		body []any
+	}{
+		{Interface + ".StateChanged", []any{string(ipc.StateSpeechDetected), "Detected speech..."}},
+		{Interface + ".Transcript", []any{"hello", true}},
+	}
+	for _, w := range want {
+		select {
+		case sig := <-signals:
+			if sig.Name != w.name {
+				t.Fatalf("got signal %s, want %s", sig.Name, w.name)
+			}
+			if len(sig.Body) != len(w.body) {
+				t.Fatalf("%s body = %v, want %v", sig.Name, sig.Body, w.body)
+			}
+			for i := range w.body {
+				if sig.Body[i] != w.body[i] {
+					t.Errorf("%s body[%d] = %v, want %v", sig.Name, i, sig.Body[i], w.body[i])
+				}
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %s", w.name)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package gui
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/gen2brain/beeep"
 )
@@ -23,9 +24,25 @@ type ShowFunctionCallMsg struct {
 type HideMsg struct{}
 type ShowStoppingMsg struct{}
 
+// ShowDictationPausedMsg is sent while connected but waiting for the code
+// word which starts dictation.
+type ShowDictationPausedMsg struct {
+	StartPhrase string
+}
+type ShowDictationStartedMsg struct{}
+
+// ShowPartialTranscriptMsg carries the transcript of the speech so far,
+// before the final transcript arrives.
+type ShowPartialTranscriptMsg struct {
+	Text string
+}
+
 type ShowTranscriptMsg struct {
 	Text   string
 	IsUser bool
+	// Raw is the transcript as received, if it was corrected or
+	// post-processed into Text
+	Raw string
 }
 
 func (m *ShowListeningMsg) IsMsg() bool          { return true }
@@ -37,6 +54,9 @@ func (m *ShowFunctionCallMsg) IsMsg() bool       { return true }
 func (m *HideMsg) IsMsg() bool                   { return true }
 func (m *ShowStoppingMsg) IsMsg() bool           { return true }
 func (m *ShowTranscriptMsg) IsMsg() bool         { return true }
+func (m *ShowDictationPausedMsg) IsMsg() bool    { return true }
+func (m *ShowDictationStartedMsg) IsMsg() bool   { return true }
+func (m *ShowPartialTranscriptMsg) IsMsg() bool  { return true }
 
 type StatusSink interface {
 	Send(msg Msg)
@@ -72,7 +92,12 @@ func (g *GUI) Send(msg Msg) {
 	g.Chan <- msg
 }
 
+// partialNotifyInterval limits how often partial transcripts are shown,
+// as they arrive many times a second.
+const partialNotifyInterval = time.Second
+
 func (g *GUI) Run() {
+	var lastPartial time.Time
 	for {
 		select {
 		case msg := <-g.Chan:
@@ -112,6 +137,19 @@ func (g *GUI) Run() {
 			case *ShowStoppingMsg:
 				text = "Stopping listening"
 				image = iconPath("media-playback-stop")
+			case *ShowDictationPausedMsg:
+				text = "Dictation paused, say \"" + msg.(*ShowDictationPausedMsg).StartPhrase + "\" to start"
+				image = iconPath("media-playback-pause")
+			case *ShowDictationStartedMsg:
+				text = "Dictation started"
+				image = iconPath("audio-input-microphone")
+			case *ShowPartialTranscriptMsg:
+				if time.Since(lastPartial) < partialNotifyInterval {
+					continue
+				}
+				lastPartial = time.Now()
+				text = msg.(*ShowPartialTranscriptMsg).Text
+				image = iconPath("text-x-generic")
 			default:
 				continue
 			}
@@ -20,6 +20,9 @@ func TestGUISendReceive(t *testing.T) {
 		&HideMsg{},
 		&ShowStoppingMsg{},
 		&ShowTranscriptMsg{Text: "hello", IsUser: true},
+		&ShowDictationPausedMsg{StartPhrase: "start dictation"},
+		&ShowDictationStartedMsg{},
+		&ShowPartialTranscriptMsg{Text: "hel"},
 	}
 
 	go g.Run()
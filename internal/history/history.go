@@ -0,0 +1,152 @@
+// Package history keeps a record of transcripts and assistant replies, so
+// dictation which went into the wrong window can be recovered. Entries are
+// appended to a JSON lines file, one object per line.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindTranscript Kind = "transcript"
+	KindAssistant  Kind = "assistant"
+)
+
+type Entry struct {
+	Time time.Time `json:"time"`
+	Kind Kind      `json:"kind"`
+	Text string    `json:"text"`
+	// Raw is the transcript before correction and post-processing, if
+	// they changed it.
+	Raw   string `json:"raw,omitempty"`
+	Mode  string `json:"mode,omitempty"`
+	Lang  string `json:"lang,omitempty"`
+	Model string `json:"model,omitempty"`
+	// DurationMs is how long the user spoke or, for assistant replies, how
+	// long the reply took to generate.
+	DurationMs int64 `json:"duration_ms,omitempty"`
+}
+
+// DefaultPath returns $XDG_STATE_HOME/voxinput/history.jsonl, or
+// ~/.local/state/voxinput/history.jsonl when XDG_STATE_HOME is not set.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("history: cannot determine state directory: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "voxinput", "history.jsonl"), nil
+}
+
+// Append adds e to the history file at path, creating it if needed. The
+// file is only readable by the user as it holds everything they dictated.
+func Append(path string, e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	line = append(line, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	// A single write, so entries from concurrent writers do not interleave
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
+
+type Filter struct {
+	// Since drops entries older than this, if set.
+	Since time.Time
+	// Grep is a case-insensitive regular expression the text or raw
+	// transcript must match, if set.
+	Grep string
+	// Limit keeps only the last Limit entries, if positive.
+	Limit int
+}
+
+// Read returns the entries of the history file at path which pass f, oldest
+// first. A missing file has no entries. Lines which cannot be parsed, such
+// as one cut short by a crash, are skipped.
+func Read(path string, f Filter) ([]Entry, error) {
+	var grep *regexp.Regexp
+	if f.Grep != "" {
+		var err error
+		if grep, err = regexp.Compile("(?i)" + f.Grep); err != nil {
+			return nil, fmt.Errorf("history: invalid pattern: %w", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if !f.Since.IsZero() && e.Time.Before(f.Since) {
+			continue
+		}
+		if grep != nil && !grep.MatchString(e.Text) && !grep.MatchString(e.Raw) {
+			continue
+		}
+		entries = append(entries, e)
+		if f.Limit > 0 && len(entries) > 2*f.Limit {
+			entries = append(entries[:0], entries[len(entries)-f.Limit:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	if f.Limit > 0 && len(entries) > f.Limit {
+		entries = entries[len(entries)-f.Limit:]
+	}
+	return entries, nil
+}
+
+// ParseSince parses a --since value: a duration before now such as "2h" or
+// "30m", a date such as "2006-01-02" in local time, or an RFC 3339 time.
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("history: invalid time %q (expected a duration such as 2h, a date or an RFC 3339 time)", s)
+}
@@ -0,0 +1,119 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history.jsonl")
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	entries := []Entry{
+		{Time: start, Kind: KindTranscript, Text: "Hello world", Mode: "transcription", Lang: "en", DurationMs: 1200},
+		{Time: start.Add(time.Minute), Kind: KindTranscript, Text: "Run it on LocalAI.", Raw: "run it on local eye"},
+		{Time: start.Add(2 * time.Minute), Kind: KindAssistant, Text: "Done."},
+	}
+	for _, e := range entries {
+		if err := Append(path, e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("history file mode = %o, want 600", perm)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all", Filter{}, []string{"Hello world", "Run it on LocalAI.", "Done."}},
+		{"since", Filter{Since: start.Add(30 * time.Second)}, []string{"Run it on LocalAI.", "Done."}},
+		{"grep text", Filter{Grep: "HELLO"}, []string{"Hello world"}},
+		{"grep raw", Filter{Grep: "local eye"}, []string{"Run it on LocalAI."}},
+		{"limit", Filter{Limit: 2}, []string{"Run it on LocalAI.", "Done."}},
+		{"limit and grep", Filter{Grep: "o", Limit: 1}, []string{"Done."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(path, tt.filter)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			var texts []string
+			for _, e := range got {
+				texts = append(texts, e.Text)
+			}
+			if len(texts) != len(tt.want) {
+				t.Fatalf("Read = %q, want %q", texts, tt.want)
+			}
+			for i := range texts {
+				if texts[i] != tt.want[i] {
+					t.Fatalf("Read = %q, want %q", texts, tt.want)
+				}
+			}
+		})
+	}
+
+	got, _ := Read(path, Filter{Limit: 1, Grep: "hello"})
+	if len(got) != 1 || got[0].Lang != "en" || got[0].DurationMs != 1200 || !got[0].Time.Equal(start) {
+		t.Errorf("entry did not round trip: %+v", got)
+	}
+}
+
+func TestReadSkipsBadLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	data := `{"time":"2026-01-02T10:00:00Z","kind":"transcript","text":"one"}
+not json
+{"time":"2026-01-02T10:01:00Z","kind":"transcript","te`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(path, Filter{})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(got) != 1 || got[0].Text != "one" {
+		t.Errorf("Read = %+v, want only the valid entry", got)
+	}
+}
+
+func TestReadMissing(t *testing.T) {
+	got, err := Read(filepath.Join(t.TempDir(), "none.jsonl"), Filter{})
+	if err != nil || got != nil {
+		t.Errorf("Read = %v, %v, want no entries", got, err)
+	}
+	if _, err := Read("none.jsonl", Filter{Grep: "("}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"2h":                   now.Add(-2 * time.Hour),
+		"2026-01-01":           time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		"2026-01-01T08:30:00Z": time.Date(2026, 1, 1, 8, 30, 0, 0, time.UTC),
+	}
+	for in, want := range tests {
+		got, err := ParseSince(in, now)
+		if err != nil {
+			t.Errorf("ParseSince(%q): %v", in, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("ParseSince(%q) = %v, want %v", in, got, want)
+		}
+	}
+	if _, err := ParseSince("yesterday", now); err == nil {
+		t.Error("expected an error for an invalid time")
+	}
+}
@@ -0,0 +1,32 @@
+package input
+
+import "strings"
+
+// Retype returns the commands which turn text typed just before the cursor
+// into target, by erasing what differs with BackSpace and typing the rest.
+// Both are compared the way TypeText types them with dotool: trimmed, with
+// runs of whitespace as a single space. A space at the start of the text to
+// type is pressed as a key, as TypeText would drop it.
+func Retype(typed, target string) []Command {
+	have := []rune(strings.Join(strings.Fields(typed), " "))
+	want := []rune(strings.Join(strings.Fields(target), " "))
+
+	common := 0
+	for common < len(have) && common < len(want) && have[common] == want[common] {
+		common++
+	}
+
+	var cmds []Command
+	for range len(have) - common {
+		cmds = append(cmds, Command{Action: "key", Args: "backspace"})
+	}
+	rest := string(want[common:])
+	if strings.HasPrefix(rest, " ") {
+		cmds = append(cmds, Command{Action: "key", Args: "space"})
+		rest = rest[1:]
+	}
+	if rest != "" {
+		cmds = append(cmds, Command{Action: "type", Args: rest})
+	}
+	return cmds
+}
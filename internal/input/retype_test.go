@@ -0,0 +1,37 @@
+package input
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRetype(t *testing.T) {
+	bs := Command{Action: "key", Args: "backspace"}
+	space := Command{Action: "key", Args: "space"}
+	typ := func(s string) Command { return Command{Action: "type", Args: s} }
+
+	tests := []struct {
+		name          string
+		typed, target string
+		want          []Command
+	}{
+		{"nothing", "", "", nil},
+		{"first delta", "", " Hello", []Command{typ("Hello")}},
+		{"append word", "Hello", "Hello world", []Command{space, typ("world")}},
+		{"append within word", "Hel", "Hello", []Command{typ("lo")}},
+		{"same", "Hello world", " Hello  world ", nil},
+		{"fix ending", "Hello word", "Hello world.", []Command{bs, typ("ld.")}},
+		{"fix word", "I red it", "I read it.", []Command{bs, bs, bs, bs, typ("ad it.")}},
+		{"erase", "Grüße", "", []Command{bs, bs, bs, bs, bs}},
+		{"erase to space", "one two", "one", []Command{bs, bs, bs, bs}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Retype(tt.typed, tt.target)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Retype(%q, %q) = %v, want %v", tt.typed, tt.target, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,13 +2,27 @@ package ipc
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
+// helloTimeout is how long Connect waits for the hello event. Daemons
+// predating the handshake never send one.
+const helloTimeout = time.Second
+
 type Client struct {
 	conn    net.Conn
 	scanner *bufio.Scanner
+	lastID  atomic.Uint64
+	hello   *Hello
+	// pending is an event read while waiting for the hello which must be
+	// returned by the next ReadEvent.
+	pending *Event
 }
 
 func Connect(path string) (*Client, error) {
@@ -17,13 +31,55 @@ func Connect(path string) (*Client, error) {
 		return nil, fmt.Errorf("connect to %s: %w", path, err)
 	}
 
-	return &Client{
+	c := &Client{
 		conn:    conn,
 		scanner: bufio.NewScanner(conn),
-	}, nil
+	}
+	if err := c.readHello(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connect to %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+func (c *Client) readHello() error {
+	c.conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	e, err := c.ReadEvent()
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		// The scanner stops for good after an error
+		c.scanner = bufio.NewScanner(c.conn)
+	case err != nil:
+		return fmt.Errorf("read hello: %w", err)
+	case e.Kind == EventHello && e.Hello != nil:
+		c.hello = e.Hello
+	default:
+		c.pending = &e
+	}
+	return nil
+}
+
+// Hello returns the hello event sent by the daemon, or nil if the daemon
+// predates the handshake.
+func (c *Client) Hello() *Hello {
+	return c.hello
+}
+
+// NewCommand returns a command with an ID that is unique for this client,
+// so its reply can be told apart from replies to other commands.
+func (c *Client) NewCommand(kind CommandKind) Command {
+	return Command{Kind: kind, ID: strconv.FormatUint(c.lastID.Add(1), 10)}
 }
 
 func (c *Client) ReadEvent() (Event, error) {
+	if e := c.pending; e != nil {
+		c.pending = nil
+		return *e, nil
+	}
 	return DecodeEvent(c.scanner)
 }
 
@@ -31,6 +87,51 @@ func (c *Client) SendCommand(cmd Command) error {
 	return EncodeCommand(c.conn, cmd)
 }
 
+// Request sends cmd and waits up to timeout for the server's reply,
+// discarding any other events received in the meantime. An ID is assigned
+// if cmd has none. A reply reporting failure is returned with an error.
+// It must not be used while another goroutine is reading events from the
+// client.
+func (c *Client) Request(cmd Command, timeout time.Duration) (Event, error) {
+	if cmd.ID == "" {
+		cmd.ID = c.NewCommand(cmd.Kind).ID
+	}
+	if err := c.SendCommand(cmd); err != nil {
+		return Event{}, fmt.Errorf("send command: %w", err)
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		e, err := c.ReadEvent()
+		if err != nil {
+			return Event{}, fmt.Errorf("wait for reply to %s: %w", cmd.Kind, err)
+		}
+		if e.Kind != EventReply || e.ID != cmd.ID {
+			continue
+		}
+		if !e.OK {
+			return e, fmt.Errorf("%s failed: %s", cmd.Kind, e.Error)
+		}
+		return e, nil
+	}
+}
+
+// Subscribe limits the events sent to this client to the given kinds, nil
+// meaning all of them, and optionally requests the replay of recent events.
+// Call it before reading events; the daemon must send a hello with protocol
+// 1.1 or later.
+func (c *Client) Subscribe(kinds []EventKind, replay bool, timeout time.Duration) error {
+	args, err := json.Marshal(SubscribeArgs{Kinds: kinds, Replay: replay})
+	if err != nil {
+		return fmt.Errorf("marshal subscribe args: %w", err)
+	}
+	cmd := c.NewCommand(CommandSubscribe)
+	cmd.Args = args
+	_, err = c.Request(cmd, timeout)
+	return err
+}
+
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
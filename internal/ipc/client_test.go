@@ -1,6 +1,7 @@
 package ipc
 
 import (
+	"net"
 	"path/filepath"
 	"testing"
 	"time"
@@ -85,3 +86,160 @@ func TestClientReconnectFails(t *testing.T) {
 		t.Error("expected error connecting to nonexistent socket")
 	}
 }
+
+func TestClientRequest(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	srv, err := NewServer(sock)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	srv.Broadcast(Event{Kind: EventLog, Text: "replayed"})
+
+	cli, err := Connect(sock)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer cli.Close()
+
+	go func() {
+		cmd := <-srv.Commands()
+		srv.Broadcast(Event{Kind: EventStatus, Text: "broadcast"})
+		cmd.Reply(Event{Kind: EventReply, Text: "recording", Recording: true})
+	}()
+
+	e, err := cli.Request(Command{Kind: CommandToggle}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if e.Kind != EventReply || !e.Recording || e.Ts == 0 {
+		t.Errorf("got %+v, want reply with recording=true and a timestamp", e)
+	}
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	srv, err := NewServer(sock)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	cli, err := Connect(sock)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer cli.Close()
+
+	if _, err := cli.Request(Command{Kind: CommandStatus}, 100*time.Millisecond); err == nil {
+		t.Fatal("expected timeout error")
+	}
+}
+
+func TestClientHello(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	srv, err := NewServer(sock)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	srv.SetCapabilities(Capabilities{Mode: "assistant", AEC: true, Tools: []string{"input_control"}})
+	srv.Broadcast(Event{Kind: EventStatus, Text: "replayed"})
+
+	cli, err := Connect(sock)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer cli.Close()
+
+	h := cli.Hello()
+	if h == nil {
+		t.Fatal("expected hello")
+	}
+	if h.Protocol != ProtocolVersion || h.Capabilities.Mode != "assistant" || !h.Capabilities.AEC || len(h.Capabilities.Tools) != 1 {
+		t.Errorf("got hello %+v", h)
+	}
+	if err := h.CheckProtocol(); err != nil {
+		t.Errorf("CheckProtocol: %v", err)
+	}
+
+	// The hello is not returned by ReadEvent and comes before the replay
+	e, err := cli.ReadEvent()
+	if err != nil {
+		t.Fatalf("ReadEvent: %v", err)
+	}
+	if e.Text != "replayed" {
+		t.Errorf("got %+v, want replayed status", e)
+	}
+}
+
+func TestClientWithoutHello(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	// A daemon predating the handshake which replays one event, then one
+	// which sends nothing
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		EncodeEvent(conn, Event{Kind: EventStatus, Text: "replayed"})
+		time.Sleep(time.Second)
+	}()
+
+	cli, err := Connect(sock)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer cli.Close()
+	if cli.Hello() != nil {
+		t.Error("expected no hello")
+	}
+	e, err := cli.ReadEvent()
+	if err != nil || e.Text != "replayed" {
+		t.Errorf("got %+v, %v, want replayed status", e, err)
+	}
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(helloTimeout + 100*time.Millisecond)
+		EncodeEvent(conn, Event{Kind: EventStatus, Text: "late"})
+		time.Sleep(time.Second)
+	}()
+
+	cli2, err := Connect(sock)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer cli2.Close()
+	if cli2.Hello() != nil {
+		t.Error("expected no hello")
+	}
+	e, err = cli2.ReadEvent()
+	if err != nil || e.Text != "late" {
+		t.Errorf("got %+v, %v, want late status", e, err)
+	}
+}
+
+func TestHelloCheckProtocol(t *testing.T) {
+	h := &Hello{Protocol: "99.0.0", Version: "9.0.0"}
+	if err := h.CheckProtocol(); err == nil {
+		t.Error("expected error for a different major version")
+	}
+	h.Protocol = "1.99.0"
+	if err := h.CheckProtocol(); err != nil {
+		t.Errorf("minor versions should be compatible: %v", err)
+	}
+}
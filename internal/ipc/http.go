@@ -0,0 +1,240 @@
+package ipc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxArgsSize limits the size of a command request body.
+const maxArgsSize = 64 << 10
+
+// HTTPServer serves the socket protocol over loopback HTTP for clients such
+// as browser extensions which cannot open unix sockets. Commands are posted
+// to /v1/commands/{kind} and answered with the reply event; events are
+// streamed from /v1/events as server-sent events. Every request must carry
+// the token, either as a bearer token or as the token query parameter.
+type HTTPServer struct {
+	srv      *Server
+	token    string
+	listener net.Listener
+	http     *http.Server
+}
+
+// NewHTTPServer serves srv's commands and events on addr, which must be a
+// loopback address.
+func NewHTTPServer(srv *Server, addr, token string) (*HTTPServer, error) {
+	if token == "" {
+		return nil, errors.New("http: a token is required")
+	}
+	if err := checkLoopback(addr); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", addr, err)
+	}
+
+	h := &HTTPServer{srv: srv, token: token, listener: ln}
+	h.http = &http.Server{Handler: h.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := h.http.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("ipc http: serve error: %v", err)
+		}
+	}()
+
+	return h, nil
+}
+
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid HTTP address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("HTTP address %q is not a loopback address", addr)
+	}
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (h *HTTPServer) Addr() net.Addr {
+	return h.listener.Addr()
+}
+
+// Handler returns the API's handler, including the token check.
+func (h *HTTPServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/hello", h.handleHello)
+	mux.HandleFunc("POST /v1/commands/{kind}", h.handleCommand)
+	mux.HandleFunc("GET /v1/events", h.handleEvents)
+	return h.authorize(mux)
+}
+
+func (h *HTTPServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			// EventSource cannot set headers
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("ipc http: write error: %v", err)
+	}
+}
+
+func (h *HTTPServer) handleHello(w http.ResponseWriter, r *http.Request) {
+	h.srv.mu.Lock()
+	hello := h.srv.hello
+	h.srv.mu.Unlock()
+	writeJSON(w, http.StatusOK, Event{Kind: EventHello, Ts: time.Now().UnixMilli(), Hello: &hello})
+}
+
+// handleCommand passes the command to the listener and answers with its
+// reply event. The request body, if any, holds the command arguments.
+func (h *HTTPServer) handleCommand(w http.ResponseWriter, r *http.Request) {
+	cmd := Command{Kind: CommandKind(r.PathValue("kind")), ID: r.Header.Get("X-Request-ID")}
+	if cmd.Kind == CommandSubscribe {
+		http.Error(w, "use /v1/events to receive events", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxArgsSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if !json.Valid(body) {
+			http.Error(w, "arguments are not valid JSON", http.StatusBadRequest)
+			return
+		}
+		cmd.Args = body
+	}
+
+	replies := make(chan Event, 1)
+	cmd = cmd.WithReply(func(e Event) {
+		if e.Ts == 0 {
+			e.Ts = time.Now().UnixMilli()
+		}
+		replies <- e
+	})
+	if err := h.srv.submit(cmd); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	select {
+	case e := <-replies:
+		status := http.StatusOK
+		if !e.OK {
+			status = http.StatusUnprocessableEntity
+		}
+		writeJSON(w, status, e)
+	case <-r.Context().Done():
+	case <-time.After(RequestTimeout):
+		http.Error(w, fmt.Sprintf("%s: timed out waiting for the listener", cmd.Kind), http.StatusGatewayTimeout)
+	}
+}
+
+// handleEvents streams events as server-sent events, starting with the
+// hello. The kind query parameter, repeatable or comma separated, limits
+// the event kinds, and replay=1 sends the recent events first.
+func (h *HTTPServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var kinds []EventKind
+	if values, ok := q["kind"]; ok {
+		kinds = []EventKind{}
+		for _, v := range values {
+			for _, k := range strings.Split(v, ",") {
+				if k != "" {
+					kinds = append(kinds, EventKind(k))
+				}
+			}
+		}
+	}
+	replay := q.Get("replay") == "1" || q.Get("replay") == "true"
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	conn := &sseConn{w: w, rc: http.NewResponseController(w), done: make(chan struct{})}
+	if err := conn.rc.Flush(); err != nil {
+		log.Printf("ipc http: events stream error: %v", err)
+		return
+	}
+	c := h.srv.attach(conn, kinds, replay)
+
+	select {
+	case <-r.Context().Done():
+	case <-conn.done:
+	}
+	h.srv.removeClient(c)
+}
+
+// Close stops the HTTP server and ends all event streams.
+func (h *HTTPServer) Close() error {
+	return h.http.Close()
+}
+
+// sseConn writes events to an HTTP response as server-sent events. Once
+// closed it drops events, as the handler may have returned.
+type sseConn struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+func (c *sseConn) WriteEvent(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.rc.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := fmt.Fprintf(c.w, "event: %s\ndata: %s\n\n", e.Kind, data); err != nil {
+		return err
+	}
+	return c.rc.Flush()
+}
+
+func (c *sseConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package ipc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHTTPServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	srv, err := NewServer("")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	h, err := NewHTTPServer(srv, "127.0.0.1:0", "secret")
+	if err != nil {
+		t.Fatalf("NewHTTPServer: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	return srv, "http://" + h.Addr().String()
+}
+
+func TestHTTPServerRejectsNonLoopback(t *testing.T) {
+	srv, err := NewServer("")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	for _, addr := range []string{"0.0.0.0:0", ":0", "192.0.2.1:0"} {
+		if h, err := NewHTTPServer(srv, addr, "secret"); err == nil {
+			h.Close()
+			t.Errorf("NewHTTPServer(%q) succeeded, want error", addr)
+		}
+	}
+	if _, err := NewHTTPServer(srv, "127.0.0.1:0", ""); err == nil {
+		t.Error("NewHTTPServer without token succeeded, want error")
+	}
+}
+
+func TestHTTPServerToken(t *testing.T) {
+	_, base := newTestHTTPServer(t)
+
+	resp, err := http.Get(base + "/v1/hello")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status without token = %d, want 401", resp.StatusCode)
+	}
+
+	resp, err = http.Get(base + "/v1/hello?token=secret")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	var e Event
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if e.Kind != EventHello || e.Hello == nil || e.Hello.Protocol != ProtocolVersion {
+		t.Errorf("got %+v, want hello", e)
+	}
+}
+
+func TestHTTPServerCommand(t *testing.T) {
+	srv, base := newTestHTTPServer(t)
+
+	go func() {
+		cmd := <-srv.Commands()
+		var args SetArgs
+		if err := cmd.DecodeArgs(&args); err != nil || cmd.Kind != CommandSet || args.Lang == nil {
+			cmd.Reply(Event{Error: "unexpected command"})
+			return
+		}
+		cmd.Reply(Event{Settings: &Settings{Lang: *args.Lang}})
+
+		cmd = <-srv.Commands()
+		cmd.Reply(Event{Text: "idle", Error: "failed to start session"})
+	}()
+
+	post := func(kind, body string) (*http.Response, Event) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, base+"/v1/commands/"+kind, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Request-ID", "7")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s: %v", kind, err)
+		}
+		defer resp.Body.Close()
+		var e Event
+		json.NewDecoder(resp.Body).Decode(&e)
+		return resp, e
+	}
+
+	resp, e := post("set", `{"lang":"de"}`)
+	if resp.StatusCode != http.StatusOK || !e.OK || e.ID != "7" || e.Settings == nil || e.Settings.Lang != "de" {
+		t.Errorf("set: status %d, reply %+v", resp.StatusCode, e)
+	}
+
+	resp, e = post("record", "")
+	if resp.StatusCode != http.StatusUnprocessableEntity || e.OK || e.Error == "" {
+		t.Errorf("record: status %d, reply %+v, want failed reply", resp.StatusCode, e)
+	}
+
+	resp, _ = post("set", "{not json")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid args: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestHTTPServerEvents(t *testing.T) {
+	srv, base := newTestHTTPServer(t)
+
+	srv.Broadcast(Event{Kind: EventTranscript, Text: "before"})
+	srv.Broadcast(Event{Kind: EventLog, Text: "noise"})
+
+	req, err := http.NewRequest(http.MethodGet, base+"/v1/events?kind=transcript&replay=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	events := make(chan Event, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(data), &e); err == nil {
+				events <- e
+			}
+		}
+		close(events)
+	}()
+
+	next := func() Event {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return Event{}
+		}
+	}
+
+	if e := next(); e.Kind != EventHello {
+		t.Errorf("first event = %+v, want hello", e)
+	}
+	if e := next(); e.Text != "before" {
+		t.Errorf("replayed event = %+v, want transcript before", e)
+	}
+
+	srv.Broadcast(Event{Kind: EventLog, Text: "filtered"})
+	srv.Broadcast(Event{Kind: EventTranscript, Text: "after"})
+	if e := next(); e.Text != "after" {
+		t.Errorf("event = %+v, want transcript after", e)
+	}
+}
@@ -5,10 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/richiejp/VoxInput/internal/gui"
+	"github.com/richiejp/VoxInput/internal/history"
+	"github.com/richiejp/VoxInput/internal/semver"
 )
 
+// ProtocolVersion is the version of the socket protocol. The major version
+// changes when old clients can no longer understand the daemon.
+const ProtocolVersion = "1.6.0"
+
+// RequestTimeout bounds how long the socket, HTTP and D-Bus clients wait for
+// the listener's reply to a command. Starting a session waits up to the
+// transcription timeout for the realtime connection; when that is set
+// longer than this, a client may give up on a session which still starts.
+const RequestTimeout = 2 * time.Minute
+
 type EventKind string
 
 const (
@@ -18,15 +31,111 @@ const (
 	EventFunctionCall EventKind = "function_call"
 	EventLog          EventKind = "log"
 	EventError        EventKind = "error"
+	// EventReply answers a command and is only sent to the client that
+	// sent it.
+	EventReply EventKind = "reply"
+	// EventHello is sent first to every client on connect.
+	EventHello EventKind = "hello"
+	// EventSettings reports the settings after they were changed with
+	// CommandSet or a reload.
+	EventSettings EventKind = "settings"
+	// EventPartial carries the transcript of the current utterance so far.
+	// It is followed by a transcript event and is not replayed.
+	EventPartial EventKind = "partial"
+)
+
+// EventKinds lists the kinds of event a client can subscribe to, in the
+// order they are documented. Hello and reply events are always sent.
+var EventKinds = []EventKind{EventStatus, EventTranscript, EventPartial, EventAssistant,
+	EventFunctionCall, EventSettings, EventLog, EventError}
+
+// State is what the listener is doing, carried by status and function call
+// events.
+type State string
+
+const (
+	StateIdle            State = "idle"
+	StateListening       State = "listening"
+	StateSpeechDetected  State = "speech_detected"
+	StateTranscribing    State = "transcribing"
+	StateSpeechSubmitted State = "speech_submitted"
+	StateGenerating      State = "generating_response"
+	StateToolCall        State = "tool_call"
+	// StateDictationPaused is connected but waiting for the start code
+	// word; transcripts are not typed.
+	StateDictationPaused State = "dictation_paused"
 )
 
+// Hello describes the daemon to a newly connected client.
+type Hello struct {
+	Protocol     string       `json:"protocol"`
+	Version      string       `json:"version"`
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// Capabilities is what the running daemon was configured with.
+type Capabilities struct {
+	Mode  string   `json:"mode"`
+	AEC   bool     `json:"aec"`
+	Tools []string `json:"tools,omitempty"`
+}
+
+// CheckProtocol returns an error if the daemon speaks a protocol major
+// version other than ours.
+func (h *Hello) CheckProtocol() error {
+	theirs, err := semver.Parse([]byte(h.Protocol))
+	if err != nil {
+		return fmt.Errorf("invalid protocol version %q: %w", h.Protocol, err)
+	}
+	ours, err := semver.Parse([]byte(ProtocolVersion))
+	if err != nil {
+		return fmt.Errorf("invalid protocol version %q: %w", ProtocolVersion, err)
+	}
+	if theirs.Major() != ours.Major() {
+		return fmt.Errorf("daemon %s speaks protocol %s, this client speaks %s",
+			h.Version, h.Protocol, ProtocolVersion)
+	}
+	return nil
+}
+
 type Event struct {
 	Kind      EventKind `json:"kind"`
 	Ts        int64     `json:"ts"`
 	Text      string    `json:"text"`
 	Detail    string    `json:"detail,omitempty"`
 	IsUser    bool      `json:"is_user,omitempty"`
+	Raw       string    `json:"raw,omitempty"`
 	Recording bool      `json:"recording,omitempty"`
+	State     State     `json:"state,omitempty"`
+	// ID, OK and Error are only set on replies. ID is that of the command
+	// being answered.
+	ID       string    `json:"id,omitempty"`
+	OK       bool      `json:"ok,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Hello    *Hello    `json:"hello,omitempty"`
+	Settings *Settings `json:"settings,omitempty"`
+	// History is set on replies to CommandHistory.
+	History []history.Entry `json:"history,omitempty"`
+}
+
+// Settings are the listener settings which can be changed at runtime. The
+// JSON keys match the config file profile keys.
+type Settings struct {
+	Lang         string `json:"lang"`
+	Prompt       string `json:"prompt"`
+	Model        string `json:"transcription_model"`
+	Mode         string `json:"mode"`
+	Instructions string `json:"assistant_instructions"`
+}
+
+// SetArgs are the arguments of CommandSet. Only the fields present are
+// changed.
+type SetArgs struct {
+	Lang         *string `json:"lang,omitempty"`
+	Prompt       *string `json:"prompt,omitempty"`
+	Model        *string `json:"transcription_model,omitempty"`
+	Mode         *string `json:"mode,omitempty"`
+	Instructions *string `json:"assistant_instructions,omitempty"`
 }
 
 type CommandKind string
@@ -34,11 +143,87 @@ type CommandKind string
 const (
 	CommandRecord CommandKind = "record"
 	CommandStop   CommandKind = "stop"
+	CommandToggle CommandKind = "toggle"
+	CommandStatus CommandKind = "status"
 	CommandQuit   CommandKind = "quit"
+	CommandReload CommandKind = "reload"
+	// CommandSubscribe is handled by the server itself; see SubscribeArgs.
+	CommandSubscribe CommandKind = "subscribe"
+	// CommandSet changes settings of the running listener; see SetArgs.
+	CommandSet CommandKind = "set"
+	// CommandHistory fetches recent history entries; see HistoryArgs.
+	CommandHistory CommandKind = "history"
+	// CommandUndo erases the text typed for the last utterance.
+	CommandUndo CommandKind = "undo"
 )
 
 type Command struct {
 	Kind CommandKind `json:"kind"`
+	// ID is chosen by the client and copied into the reply so it can be
+	// matched with the command.
+	ID   string          `json:"id,omitempty"`
+	Args json.RawMessage `json:"args,omitempty"`
+
+	reply func(Event)
+}
+
+// SubscribeArgs are the arguments of CommandSubscribe. Clients which do not
+// subscribe shortly after connecting receive all events, starting with the
+// replay of recent events.
+type SubscribeArgs struct {
+	// Kinds lists the event kinds to receive. Null or missing means all
+	// kinds; an empty list means none, so only replies are received.
+	Kinds []EventKind `json:"kinds"`
+	// Replay requests the recent events buffered by the server.
+	Replay bool `json:"replay"`
+}
+
+// HistoryArgs are the optional arguments of CommandHistory.
+type HistoryArgs struct {
+	// Limit is the number of most recent entries to return, 20 if zero.
+	Limit int `json:"limit,omitempty"`
+	// Since drops older entries: a duration such as "2h", a date or an
+	// RFC 3339 time.
+	Since string `json:"since,omitempty"`
+	// Grep is a case-insensitive regular expression the text must match.
+	Grep string `json:"grep,omitempty"`
+}
+
+// DecodeArgs unmarshals the command arguments into v.
+func (c Command) DecodeArgs(v any) error {
+	if len(c.Args) == 0 {
+		return fmt.Errorf("%s: missing arguments", c.Kind)
+	}
+	if err := json.Unmarshal(c.Args, v); err != nil {
+		return fmt.Errorf("%s: invalid arguments: %w", c.Kind, err)
+	}
+	return nil
+}
+
+// Reply sends e back to the client which sent the command as a reply
+// event. The reply succeeds unless e.Error is set. It does nothing for
+// commands that did not come from a client.
+func (c Command) Reply(e Event) {
+	if c.reply == nil {
+		return
+	}
+	e.Kind = EventReply
+	e.ID = c.ID
+	e.OK = e.Error == ""
+	c.reply(e)
+}
+
+// WithReply returns a copy of c whose replies are passed to reply. It lets
+// front ends other than the socket server, such as D-Bus, submit commands
+// to the listener and wait for the answer.
+func (c Command) WithReply(reply func(Event)) Command {
+	c.reply = reply
+	return c
+}
+
+// ReplyError answers the command with a failure.
+func (c Command) ReplyError(err error) {
+	c.Reply(Event{Error: err.Error()})
 }
 
 func EncodeEvent(w io.Writer, e Event) error {
@@ -100,25 +285,38 @@ func DecodeCommand(scanner *bufio.Scanner) (Command, error) {
 func EventFromGUIMsg(msg gui.Msg) Event {
 	switch m := msg.(type) {
 	case *gui.ShowListeningMsg:
-		return Event{Kind: EventStatus, Text: "Listening with voice audio detection...", Recording: true}
+		return stateEvent(EventStatus, StateListening, "Listening with voice audio detection...")
 	case *gui.ShowSpeechDetectedMsg:
-		return Event{Kind: EventStatus, Text: "Detected speech..."}
+		return stateEvent(EventStatus, StateSpeechDetected, "Detected speech...")
 	case *gui.ShowTranscribingMsg:
-		return Event{Kind: EventStatus, Text: "Transcribing..."}
+		return stateEvent(EventStatus, StateTranscribing, "Transcribing...")
 	case *gui.ShowSpeechSubmittedMsg:
-		return Event{Kind: EventStatus, Text: "Speech submitted..."}
+		return stateEvent(EventStatus, StateSpeechSubmitted, "Speech submitted...")
 	case *gui.ShowGeneratingResponseMsg:
-		return Event{Kind: EventStatus, Text: "Generating response..."}
+		return stateEvent(EventStatus, StateGenerating, "Generating response...")
 	case *gui.ShowFunctionCallMsg:
-		text := "Calling " + m.FunctionName
-		return Event{Kind: EventFunctionCall, Text: text, Detail: m.Arguments}
+		e := stateEvent(EventFunctionCall, StateToolCall, "Calling "+m.FunctionName)
+		e.Detail = m.Arguments
+		return e
 	case *gui.ShowStoppingMsg:
-		return Event{Kind: EventStatus, Text: "Stopping listening"}
+		return stateEvent(EventStatus, StateIdle, "Stopping listening")
+	case *gui.ShowDictationPausedMsg:
+		return stateEvent(EventStatus, StateDictationPaused, "Dictation paused, say \""+m.StartPhrase+"\" to start")
+	case *gui.ShowDictationStartedMsg:
+		return stateEvent(EventStatus, StateListening, "Dictation started")
+	case *gui.ShowPartialTranscriptMsg:
+		return Event{Kind: EventPartial, Text: m.Text, IsUser: true}
 	case *gui.ShowTranscriptMsg:
-		return Event{Kind: EventTranscript, Text: m.Text, IsUser: m.IsUser}
+		return Event{Kind: EventTranscript, Text: m.Text, IsUser: m.IsUser, Raw: m.Raw}
 	case *gui.HideMsg:
-		return Event{Kind: EventStatus, Text: ""}
+		// Sent when a transcription or response has finished and the
+		// listener is waiting for speech again
+		return stateEvent(EventStatus, StateListening, "")
 	default:
 		return Event{Kind: EventStatus, Text: "unknown"}
 	}
 }
+
+func stateEvent(kind EventKind, state State, text string) Event {
+	return Event{Kind: kind, Text: text, State: state, Recording: state != StateIdle}
+}
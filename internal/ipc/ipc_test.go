@@ -3,6 +3,7 @@ package ipc
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/richiejp/VoxInput/internal/gui"
@@ -73,8 +74,12 @@ func TestEventFromGUIMsg(t *testing.T) {
 		{&gui.ShowGeneratingResponseMsg{}, EventStatus, "Generating response..."},
 		{&gui.ShowStoppingMsg{}, EventStatus, "Stopping listening"},
 		{&gui.ShowTranscriptMsg{Text: "hi", IsUser: true}, EventTranscript, "hi"},
+		{&gui.ShowTranscriptMsg{Text: "Hi.", Raw: "hi", IsUser: true}, EventTranscript, "Hi."},
 		{&gui.ShowFunctionCallMsg{FunctionName: "foo", Arguments: "bar"}, EventFunctionCall, "Calling foo"},
 		{&gui.HideMsg{}, EventStatus, ""},
+		{&gui.ShowDictationPausedMsg{StartPhrase: "go"}, EventStatus, `Dictation paused, say "go" to start`},
+		{&gui.ShowDictationStartedMsg{}, EventStatus, "Dictation started"},
+		{&gui.ShowPartialTranscriptMsg{Text: "hel"}, EventPartial, "hel"},
 	}
 
 	for _, tt := range tests {
@@ -95,6 +100,39 @@ func TestEventFromGUIMsg(t *testing.T) {
 	if e.Recording {
 		t.Error("EventFromGUIMsg(ShowStoppingMsg): expected Recording=false")
 	}
+	e = EventFromGUIMsg(&gui.ShowTranscriptMsg{Text: "Hi.", Raw: "hi", IsUser: true})
+	if e.Raw != "hi" {
+		t.Errorf("EventFromGUIMsg(ShowTranscriptMsg): raw = %q, want hi", e.Raw)
+	}
+}
+
+func TestEventFromGUIMsgState(t *testing.T) {
+	tests := []struct {
+		msg       gui.Msg
+		wantState State
+	}{
+		{&gui.ShowListeningMsg{}, StateListening},
+		{&gui.ShowSpeechDetectedMsg{}, StateSpeechDetected},
+		{&gui.ShowTranscribingMsg{}, StateTranscribing},
+		{&gui.ShowSpeechSubmittedMsg{}, StateSpeechSubmitted},
+		{&gui.ShowGeneratingResponseMsg{}, StateGenerating},
+		{&gui.ShowFunctionCallMsg{FunctionName: "foo"}, StateToolCall},
+		{&gui.HideMsg{}, StateListening},
+		{&gui.ShowStoppingMsg{}, StateIdle},
+		{&gui.ShowTranscriptMsg{Text: "hi"}, ""},
+		{&gui.ShowDictationPausedMsg{}, StateDictationPaused},
+		{&gui.ShowDictationStartedMsg{}, StateListening},
+	}
+
+	for _, tt := range tests {
+		e := EventFromGUIMsg(tt.msg)
+		if e.State != tt.wantState {
+			t.Errorf("EventFromGUIMsg(%T): state = %q, want %q", tt.msg, e.State, tt.wantState)
+		}
+		if tt.wantState != "" && e.Recording != (tt.wantState != StateIdle) {
+			t.Errorf("EventFromGUIMsg(%T): recording = %v while %q", tt.msg, e.Recording, e.State)
+		}
+	}
 }
 
 func TestDecodeInvalidJSON(t *testing.T) {
@@ -114,3 +152,53 @@ func TestDecodeEmptyLine(t *testing.T) {
 		t.Error("expected error for empty line")
 	}
 }
+
+func TestCommandArgs(t *testing.T) {
+	var buf bytes.Buffer
+	in := Command{Kind: CommandRecord, ID: "7", Args: []byte(`{"lang":"de"}`)}
+	if err := EncodeCommand(&buf, in); err != nil {
+		t.Fatalf("encode command: %v", err)
+	}
+	got, err := DecodeCommand(bufio.NewScanner(&buf))
+	if err != nil {
+		t.Fatalf("decode command: %v", err)
+	}
+	if got.ID != "7" {
+		t.Errorf("got ID %q, want %q", got.ID, "7")
+	}
+
+	var args struct {
+		Lang string `json:"lang"`
+	}
+	if err := got.DecodeArgs(&args); err != nil {
+		t.Fatalf("DecodeArgs: %v", err)
+	}
+	if args.Lang != "de" {
+		t.Errorf("got lang %q, want %q", args.Lang, "de")
+	}
+
+	if err := (Command{Kind: CommandRecord}).DecodeArgs(&args); err == nil {
+		t.Error("expected error for missing arguments")
+	}
+}
+
+func TestCommandReply(t *testing.T) {
+	var got []Event
+	cmd := Command{Kind: CommandRecord, ID: "3", reply: func(e Event) { got = append(got, e) }}
+
+	cmd.Reply(Event{Text: "recording", Recording: true})
+	cmd.ReplyError(errors.New("boom"))
+
+	if len(got) != 2 {
+		t.Fatalf("got %d replies, want 2", len(got))
+	}
+	if got[0].Kind != EventReply || got[0].ID != "3" || !got[0].OK {
+		t.Errorf("got %+v, want successful reply with ID 3", got[0])
+	}
+	if got[1].OK || got[1].Error != "boom" {
+		t.Errorf("got %+v, want failed reply with error boom", got[1])
+	}
+
+	// Commands that did not come from a client are not answered
+	(Command{Kind: CommandRecord}).Reply(Event{})
+}
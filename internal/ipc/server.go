@@ -11,12 +11,58 @@ import (
 	"time"
 
 	"github.com/richiejp/VoxInput/internal/gui"
+	"github.com/richiejp/VoxInput/internal/semver"
 )
 
 const maxReplayEvents = 100
 
+// replayDelay is how long a new client has to subscribe before it is sent
+// the replay buffer and all events.
+const replayDelay = 100 * time.Millisecond
+
+// eventConn is the server's end of a client's event stream.
+type eventConn interface {
+	WriteEvent(e Event) error
+	Close() error
+}
+
+// socketConn is a client connected to the unix socket.
+type socketConn struct {
+	net.Conn
+}
+
+func (c socketConn) WriteEvent(e Event) error {
+	c.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	return EncodeEvent(c.Conn, e)
+}
+
 type client struct {
-	conn net.Conn
+	conn eventConn
+	// kinds is the set of event kinds the client subscribed to, nil for
+	// all kinds.
+	kinds map[EventKind]bool
+	// pending is set until the replay has been sent; broadcasts skip the
+	// client meanwhile and are sent as part of the replay instead.
+	pending bool
+	// since is the sequence number of the first event broadcast after the
+	// client connected.
+	since uint64
+	timer *time.Timer
+}
+
+func (c *client) wants(kind EventKind) bool {
+	return c.kinds == nil || c.kinds[kind]
+}
+
+// setKinds sets the event kinds the client receives, nil for all.
+func (c *client) setKinds(kinds []EventKind) {
+	c.kinds = nil
+	if kinds != nil {
+		c.kinds = make(map[EventKind]bool, len(kinds))
+		for _, k := range kinds {
+			c.kinds[k] = true
+		}
+	}
 }
 
 type Server struct {
@@ -26,25 +72,40 @@ type Server struct {
 	cmdCh    chan Command
 	done     chan struct{}
 	replay   []Event
+	// total is the number of events ever broadcast, so the sequence number
+	// of replay[i] is total-len(replay)+i.
+	total uint64
+	hello Hello
 }
 
-func SocketPath() string {
+// SocketPath returns the default socket of the named listener instance, or
+// of the default instance if instance is empty.
+func SocketPath(instance string) string {
 	dir := os.Getenv("XDG_RUNTIME_DIR")
 	if dir == "" {
 		dir = "/tmp"
 	}
+	if instance != "" {
+		return dir + "/VoxInput-" + instance + ".sock"
+	}
 	return dir + "/VoxInput.sock"
 }
 
+// NewServer listens on the unix socket at path. If path is empty the
+// server has no socket and only serves the clients of an HTTPServer.
 func NewServer(path string) (*Server, error) {
-	// Remove stale socket
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-		return nil, fmt.Errorf("remove stale socket: %w", err)
-	}
+	var ln net.Listener
+	if path != "" {
+		// Remove stale socket
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
 
-	ln, err := net.Listen("unix", path)
-	if err != nil {
-		return nil, fmt.Errorf("listen on %s: %w", path, err)
+		var err error
+		ln, err = net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("listen on %s: %w", path, err)
+		}
 	}
 
 	s := &Server{
@@ -52,9 +113,15 @@ func NewServer(path string) (*Server, error) {
 		clients:  make(map[*client]struct{}),
 		cmdCh:    make(chan Command, 16),
 		done:     make(chan struct{}),
+		hello: Hello{
+			Protocol: ProtocolVersion,
+			Version:  semver.Current().String(),
+		},
 	}
 
-	go s.acceptLoop()
+	if ln != nil {
+		go s.acceptLoop()
+	}
 
 	return s, nil
 }
@@ -72,25 +139,59 @@ func (s *Server) acceptLoop() {
 			continue
 		}
 
-		c := &client{conn: conn}
-
 		s.mu.Lock()
-		s.clients[c] = struct{}{}
-		// Send replay events to new client
-		for _, e := range s.replay {
-			if err := EncodeEvent(c.conn, e); err != nil {
-				log.Printf("ipc server: replay error: %v", err)
-				break
+		c := s.addClientLocked(socketConn{conn})
+		c.pending = true
+		// Clients which do not subscribe in time get everything
+		c.timer = time.AfterFunc(replayDelay, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if _, ok := s.clients[c]; ok && c.pending {
+				s.sendReplayLocked(c, true)
 			}
-		}
+		})
 		s.mu.Unlock()
 
-		go s.readClient(c)
+		go s.readClient(c, conn)
+	}
+}
+
+// addClientLocked registers a client and sends it the hello. s.mu must be
+// held.
+func (s *Server) addClientLocked(conn eventConn) *client {
+	c := &client{conn: conn, since: s.total}
+	s.clients[c] = struct{}{}
+	hello := s.hello
+	if err := conn.WriteEvent(Event{Kind: EventHello, Ts: time.Now().UnixMilli(), Hello: &hello}); err != nil {
+		log.Printf("ipc server: hello error: %v", err)
 	}
+	return c
 }
 
-func (s *Server) readClient(c *client) {
-	scanner := bufio.NewScanner(c.conn)
+// attach registers a client which chose its event kinds and whether it
+// wants the replay up front, so it is not held pending a subscribe.
+func (s *Server) attach(conn eventConn, kinds []EventKind, replay bool) *client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.addClientLocked(conn)
+	c.setKinds(kinds)
+	s.sendReplayLocked(c, replay)
+	return c
+}
+
+// submit queues a command for the listener, failing if the queue is full.
+func (s *Server) submit(cmd Command) error {
+	select {
+	case s.cmdCh <- cmd:
+		return nil
+	default:
+		log.Println("ipc server: command channel full, rejecting command")
+		return fmt.Errorf("%s: daemon is busy, command queue is full", cmd.Kind)
+	}
+}
+
+func (s *Server) readClient(c *client, conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
 	for {
 		cmd, err := DecodeCommand(scanner)
 		if err != nil {
@@ -100,17 +201,74 @@ func (s *Server) readClient(c *client) {
 			s.removeClient(c)
 			return
 		}
-		select {
-		case s.cmdCh <- cmd:
-		default:
-			log.Println("ipc server: command channel full, dropping command")
+		cmd.reply = func(e Event) { s.reply(c, e) }
+		if cmd.Kind == CommandSubscribe {
+			s.subscribe(c, cmd)
+			continue
+		}
+		if err := s.submit(cmd); err != nil {
+			cmd.ReplyError(err)
+		}
+	}
+}
+
+// subscribe sets the event kinds a client receives and sends it the
+// replay buffer if asked. A client which declines the replay while still
+// pending gets only the events broadcast since it connected.
+func (s *Server) subscribe(c *client, cmd Command) {
+	var args SubscribeArgs
+	if err := cmd.DecodeArgs(&args); err != nil {
+		cmd.ReplyError(err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.setKinds(args.Kinds)
+	cmd.Reply(Event{})
+
+	if c.pending || args.Replay {
+		s.sendReplayLocked(c, args.Replay)
+	}
+}
+
+// sendReplayLocked sends the client the events in the replay buffer which
+// it wants, or only those broadcast since it connected if all is false,
+// and stops holding back broadcasts. s.mu must be held.
+func (s *Server) sendReplayLocked(c *client, all bool) {
+	c.pending = false
+	first := s.total - uint64(len(s.replay))
+	for i, e := range s.replay {
+		if !all && first+uint64(i) < c.since {
+			continue
+		}
+		if !c.wants(e.Kind) {
+			continue
+		}
+		if err := c.conn.WriteEvent(e); err != nil {
+			log.Printf("ipc server: replay error: %v", err)
+			break
 		}
 	}
 }
 
+// reply sends e to a single client without adding it to the replay buffer.
+func (s *Server) reply(c *client, e Event) {
+	if e.Ts == 0 {
+		e.Ts = time.Now().UnixMilli()
+	}
+	if err := c.conn.WriteEvent(e); err != nil {
+		log.Printf("ipc server: reply error: %v", err)
+	}
+}
+
 func (s *Server) removeClient(c *client) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
 	delete(s.clients, c)
 	c.conn.Close()
 }
@@ -124,23 +282,30 @@ func (s *Server) Send(msg gui.Msg) {
 func (s *Server) Broadcast(e Event) {
 	s.mu.Lock()
 
-	s.replay = append(s.replay, e)
-	if len(s.replay) > maxReplayEvents {
-		trimmed := make([]Event, maxReplayEvents)
-		copy(trimmed, s.replay[len(s.replay)-maxReplayEvents:])
-		s.replay = trimmed
+	// Partial transcripts are superseded by the transcript and would
+	// push everything else out of the replay buffer
+	if e.Kind != EventPartial {
+		s.replay = append(s.replay, e)
+		s.total++
+		if len(s.replay) > maxReplayEvents {
+			trimmed := make([]Event, maxReplayEvents)
+			copy(trimmed, s.replay[len(s.replay)-maxReplayEvents:])
+			s.replay = trimmed
+		}
 	}
 
 	snapshot := make([]*client, 0, len(s.clients))
 	for c := range s.clients {
+		if c.pending || !c.wants(e.Kind) {
+			continue
+		}
 		snapshot = append(snapshot, c)
 	}
 	s.mu.Unlock()
 
 	var failed []*client
 	for _, c := range snapshot {
-		c.conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
-		if err := EncodeEvent(c.conn, e); err != nil {
+		if err := c.conn.WriteEvent(e); err != nil {
 			log.Printf("ipc server: broadcast error, removing client: %v", err)
 			failed = append(failed, c)
 		}
@@ -156,13 +321,24 @@ func (s *Server) Broadcast(e Event) {
 	}
 }
 
+// SetCapabilities sets the capabilities announced to clients which connect
+// from now on.
+func (s *Server) SetCapabilities(caps Capabilities) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hello.Capabilities = caps
+}
+
 func (s *Server) Commands() <-chan Command {
 	return s.cmdCh
 }
 
 func (s *Server) Close() error {
 	close(s.done)
-	err := s.listener.Close()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
 
 	s.mu.Lock()
 	for c := range s.clients {
@@ -172,8 +348,10 @@ func (s *Server) Close() error {
 	s.mu.Unlock()
 
 	// Remove socket file
-	if addr, ok := s.listener.Addr().(*net.UnixAddr); ok {
-		os.Remove(addr.Name)
+	if s.listener != nil {
+		if addr, ok := s.listener.Addr().(*net.UnixAddr); ok {
+			os.Remove(addr.Name)
+		}
 	}
 
 	return err
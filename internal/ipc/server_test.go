@@ -190,3 +190,186 @@ func TestServerStaleSocketCleanup(t *testing.T) {
 	}
 	cli.Close()
 }
+
+func TestServerRejectsCommandWhenQueueFull(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	srv, err := NewServer(sock)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	cli, err := Connect(sock)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer cli.Close()
+
+	// Nobody reads srv.Commands(), so the queue fills up
+	for i := 0; i < cap(srv.cmdCh); i++ {
+		if err := cli.SendCommand(cli.NewCommand(CommandStatus)); err != nil {
+			t.Fatalf("SendCommand: %v", err)
+		}
+	}
+
+	e, err := cli.Request(Command{Kind: CommandRecord}, 2*time.Second)
+	if err == nil {
+		t.Fatal("expected error reply when the command queue is full")
+	}
+	if e.OK || e.Error == "" {
+		t.Errorf("got %+v, want failed reply", e)
+	}
+}
+
+func TestServerSubscribe(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	srv, err := NewServer(sock)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	srv.Broadcast(Event{Kind: EventLog, Text: "old log"})
+	srv.Broadcast(Event{Kind: EventStatus, Text: "old status"})
+
+	tests := []struct {
+		name   string
+		kinds  []EventKind
+		replay bool
+		want   []string
+	}{
+		{"status without replay", []EventKind{EventStatus}, false, []string{"new status"}},
+		{"status with replay", []EventKind{EventStatus}, true, []string{"old status", "new status"}},
+		{"all with replay", nil, true, []string{"old log", "old status", "new log", "new status"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, err := Connect(sock)
+			if err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			defer cli.Close()
+
+			if err := cli.Subscribe(tt.kinds, tt.replay, 2*time.Second); err != nil {
+				t.Fatalf("Subscribe: %v", err)
+			}
+
+			srv.Broadcast(Event{Kind: EventLog, Text: "new log"})
+			srv.Broadcast(Event{Kind: EventStatus, Text: "new status"})
+
+			for _, want := range tt.want {
+				e, err := cli.ReadEvent()
+				if err != nil {
+					t.Fatalf("ReadEvent: %v", err)
+				}
+				if e.Text != want {
+					t.Errorf("got %q, want %q", e.Text, want)
+				}
+			}
+		})
+	}
+}
+
+func TestServerSubscribeNothing(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	srv, err := NewServer(sock)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	srv.Broadcast(Event{Kind: EventStatus, Text: "old status"})
+
+	cli, err := Connect(sock)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer cli.Close()
+
+	if err := cli.Subscribe([]EventKind{}, true, 2*time.Second); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	srv.Broadcast(Event{Kind: EventStatus, Text: "new status"})
+
+	// Only replies get through
+	go func() {
+		cmd := <-srv.Commands()
+		cmd.Reply(Event{Text: "idle"})
+	}()
+	e, err := cli.Request(Command{Kind: CommandStatus}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if e.Text != "idle" {
+		t.Errorf("got %+v, want idle reply", e)
+	}
+	cli.conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if e, err := cli.ReadEvent(); err == nil {
+		t.Errorf("expected no events, got %+v", e)
+	}
+}
+
+func TestServerReplayHeldForPendingClient(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	srv, err := NewServer(sock)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	srv.Broadcast(Event{Kind: EventStatus, Text: "old"})
+
+	cli, err := Connect(sock)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer cli.Close()
+
+	// Broadcast while the client has not yet subscribed or been replayed to
+	srv.Broadcast(Event{Kind: EventStatus, Text: "during"})
+	if err := cli.Subscribe(nil, false, 2*time.Second); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	e, err := cli.ReadEvent()
+	if err != nil {
+		t.Fatalf("ReadEvent: %v", err)
+	}
+	if e.Text != "during" {
+		t.Errorf("got %q, want the event broadcast since connecting", e.Text)
+	}
+}
+
+func TestServerPartialNotReplayed(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	srv, err := NewServer(sock)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Close()
+
+	srv.Broadcast(Event{Kind: EventPartial, Text: "hel"})
+	srv.Broadcast(Event{Kind: EventTranscript, Text: "hello"})
+
+	cli, err := Connect(sock)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer cli.Close()
+
+	if err := cli.Subscribe(nil, true, 2*time.Second); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	srv.Broadcast(Event{Kind: EventPartial, Text: "wor"})
+
+	for _, want := range []Event{{Kind: EventTranscript, Text: "hello"}, {Kind: EventPartial, Text: "wor"}} {
+		e, err := cli.ReadEvent()
+		if err != nil {
+			t.Fatalf("ReadEvent: %v", err)
+		}
+		if e.Kind != want.Kind || e.Text != want.Text {
+			t.Errorf("got %s %q, want %s %q", e.Kind, e.Text, want.Kind, want.Text)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Package metrics keeps runtime statistics as counters, gauges and
+// histograms and serves them in the Prometheus text exposition format.
+// Updating a metric is lock free so it may be done from audio callbacks.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default is the registry the package level constructors register with.
+var Default = NewRegistry()
+
+// LatencyBuckets are histogram buckets in seconds suited to the time
+// between speaking and seeing text.
+var LatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30}
+
+type metric interface {
+	name() string
+	write(w io.Writer) error
+}
+
+// Registry is a set of metrics with unique names.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[m.name()]; ok {
+		panic("metrics: duplicate metric " + m.name())
+	}
+	r.metrics[m.name()] = m
+}
+
+// WriteText writes every metric, sorted by name, in the Prometheus text
+// format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ms := make([]metric, len(names))
+	for i, name := range names {
+		ms[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	for _, m := range ms {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry's metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// atomicFloat is a float64 which can be added to concurrently.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name, help, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	return err
+}
+
+// Counter is a value which only goes up.
+type Counter struct {
+	n, help string
+	v       atomicFloat
+}
+
+// NewCounter creates a counter and registers it with Default.
+func NewCounter(name, help string) *Counter {
+	return Default.NewCounter(name, help)
+}
+
+// NewCounter creates a counter and registers it with r.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{n: name, help: help}
+	r.register(c)
+	return c
+}
+
+func (c *Counter) Inc()           { c.v.add(1) }
+func (c *Counter) Add(v float64)  { c.v.add(v) }
+func (c *Counter) Value() float64 { return c.v.load() }
+func (c *Counter) name() string   { return c.n }
+func (c *Counter) write(w io.Writer) error {
+	if err := writeHeader(w, c.n, c.help, "counter"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", c.n, formatFloat(c.Value()))
+	return err
+}
+
+// Gauge is a value which can go up and down.
+type Gauge struct {
+	n, help string
+	v       atomicFloat
+}
+
+// NewGauge creates a gauge and registers it with Default.
+func NewGauge(name, help string) *Gauge {
+	return Default.NewGauge(name, help)
+}
+
+// NewGauge creates a gauge and registers it with r.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{n: name, help: help}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) Set(v float64)  { g.v.set(v) }
+func (g *Gauge) Add(v float64)  { g.v.add(v) }
+func (g *Gauge) Value() float64 { return g.v.load() }
+func (g *Gauge) name() string   { return g.n }
+func (g *Gauge) write(w io.Writer) error {
+	if err := writeHeader(w, g.n, g.help, "gauge"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", g.n, formatFloat(g.Value()))
+	return err
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	n, help string
+	bounds  []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     atomicFloat
+}
+
+// NewHistogram creates a histogram with the given upper bucket bounds and
+// registers it with Default.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return Default.NewHistogram(name, help, buckets)
+}
+
+// NewHistogram creates a histogram with the given upper bucket bounds and
+// registers it with r.
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	h := &Histogram{n: name, help: help, bounds: bounds, counts: make([]atomic.Uint64, len(bounds))}
+	r.register(h)
+	return h
+}
+
+// Observe adds one observation.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	if i < len(h.counts) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	h.sum.add(v)
+}
+
+// ObserveSince observes the seconds elapsed since start.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+func (h *Histogram) name() string { return h.n }
+
+func (h *Histogram) write(w io.Writer) error {
+	if err := writeHeader(w, h.n, h.help, "histogram"); err != nil {
+		return err
+	}
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i].Load()
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.n, formatFloat(bound), cumulative); err != nil {
+			return err
+		}
+	}
+	count := h.count.Load()
+	_, err := fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %s\n%s_count %d\n",
+		h.n, count, h.n, formatFloat(h.sum.load()), h.n, count)
+	return err
+}
+
+// Server serves a registry on /metrics.
+type Server struct {
+	listener net.Listener
+	http     *http.Server
+}
+
+// NewServer serves r's metrics at http://addr/metrics. addr must be a
+// loopback address, as the metrics are not authenticated.
+func NewServer(addr string, r *Registry) (*Server, error) {
+	if err := checkLoopback(addr); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", r.Handler())
+	s := &Server{
+		listener: ln,
+		http:     &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+	}
+	go s.http.Serve(ln)
+
+	return s, nil
+}
+
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid metrics address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("metrics address %q is not a loopback address", addr)
+	}
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *Server) Close() error {
+	return s.http.Close()
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_bytes_total", "Bytes sent.")
+	g := r.NewGauge("test_reduction_db", "Echo reduction.")
+	h := r.NewHistogram("test_latency_seconds", "Latency.", []float64{1, 0.5})
+
+	c.Add(1024)
+	c.Inc()
+	g.Set(-12.5)
+	h.Observe(0.2)
+	h.Observe(0.5)
+	h.Observe(0.7)
+	h.Observe(3)
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+
+	want := `# HELP test_bytes_total Bytes sent.
+# TYPE test_bytes_total counter
+test_bytes_total 1025
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.5"} 2
+test_latency_seconds_bucket{le="1"} 3
+test_latency_seconds_bucket{le="+Inf"} 4
+test_latency_seconds_sum 4.4
+test_latency_seconds_count 4
+# HELP test_reduction_db Echo reduction.
+# TYPE test_reduction_db gauge
+test_reduction_db -12.5
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestDuplicateMetricPanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for duplicate metric")
+		}
+	}()
+	r.NewGauge("test_total", "")
+}
+
+func TestServer(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test.").Inc()
+
+	s, err := NewServer("127.0.0.1:0", r)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer s.Close()
+
+	resp, err := http.Get("http://" + s.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "test_total 1\n") {
+		t.Errorf("body missing counter:\n%s", body)
+	}
+}
+
+func TestServerRejectsNonLoopback(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:0", ":0", "192.0.2.1:0"} {
+		if s, err := NewServer(addr, NewRegistry()); err == nil {
+			s.Close()
+			t.Errorf("NewServer(%q) succeeded, want error", addr)
+		}
+	}
+}
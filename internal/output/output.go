@@ -0,0 +1,286 @@
+// Package output delivers transcripts: typed on the keyboard, pasted from
+// the clipboard, appended to a file, written to stdout, piped to a command
+// or posted to a URL. Several sinks can be used at once with Multi.
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/input"
+)
+
+// The sink names used by the output setting.
+const (
+	NameKeyboard  = "keyboard"
+	NameClipboard = "clipboard"
+	NameFile      = "file"
+	NameStdout    = "stdout"
+	NameCommand   = "command"
+	NameHTTP      = "http"
+)
+
+// Names lists the sinks in the order they are documented.
+var Names = []string{NameKeyboard, NameClipboard, NameFile, NameStdout, NameCommand, NameHTTP}
+
+// Timeout bounds how long the command and HTTP sinks may take.
+const Timeout = 30 * time.Second
+
+// Sink outputs a transcript.
+type Sink interface {
+	Output(ctx context.Context, text string) error
+}
+
+// InputError is returned by the sinks which enter text into the focused
+// window, keyboard and clipboard. Unlike the other sinks, their failure
+// usually means every later transcript would be lost too.
+type InputError struct {
+	Sink string
+	Err  error
+}
+
+func (e *InputError) Error() string {
+	return e.Sink + ": " + e.Err.Error()
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// Multi outputs to each sink in turn. A failing sink does not stop the
+// others; their errors are returned together.
+type Multi []Sink
+
+func (m Multi) Output(ctx context.Context, text string) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Output(ctx, text); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Keyboard types transcripts with an input controller. It can also type
+// the transcript of an utterance while it is spoken, which Output then
+// corrects into the final transcript. Its methods must not be called
+// concurrently.
+type Keyboard struct {
+	ctrl input.Controller
+	// typed is the partial transcript typed for the current utterance
+	typed string
+}
+
+func NewKeyboard(ctrl input.Controller) *Keyboard {
+	return &Keyboard{ctrl: ctrl}
+}
+
+func (k *Keyboard) Output(ctx context.Context, text string) error {
+	var err error
+	if k.typed != "" {
+		err = k.ctrl.ExecuteCommands(ctx, input.Retype(k.typed, text))
+		k.typed = ""
+	} else {
+		err = k.ctrl.TypeText(ctx, text)
+	}
+	if err != nil {
+		return &InputError{Sink: NameKeyboard, Err: err}
+	}
+	return nil
+}
+
+// Partial types the transcript of the current utterance so far, correcting
+// what was typed for it before.
+func (k *Keyboard) Partial(ctx context.Context, text string) error {
+	cmds := input.Retype(k.typed, text)
+	if len(cmds) == 0 {
+		return nil
+	}
+	k.typed = text
+	return k.ctrl.ExecuteCommands(ctx, cmds)
+}
+
+// Erase deletes the partial transcript typed for an utterance which turned
+// out to have nothing to output. It returns the number of characters.
+func (k *Keyboard) Erase(ctx context.Context) (int, error) {
+	if k.typed == "" {
+		return 0, nil
+	}
+	cmds := input.Retype(k.typed, "")
+	k.typed = ""
+	return len(cmds), k.ctrl.ExecuteCommands(ctx, cmds)
+}
+
+// KeepPartial leaves the partial transcript typed so far as it is, so the
+// next one is typed after it. It reports whether any was typed.
+func (k *Keyboard) KeepPartial() bool {
+	kept := k.typed != ""
+	k.typed = ""
+	return kept
+}
+
+// ErrPartialTyped is returned by Undo while a partial transcript is typed.
+var ErrPartialTyped = errors.New("a partial transcript is typed after the text")
+
+// Undo executes cmds, which erase text typed before the current utterance.
+// While a partial transcript is typed after that text, the keys would
+// erase it instead, so it fails with ErrPartialTyped.
+func (k *Keyboard) Undo(ctx context.Context, cmds []input.Command) error {
+	if k.typed != "" {
+		return ErrPartialTyped
+	}
+	return k.ctrl.ExecuteCommands(ctx, cmds)
+}
+
+// Clipboard copies transcripts to the clipboard and pastes them with the
+// platform's paste shortcut, which is faster than typing long text and
+// works with any keyboard layout. It replaces the clipboard contents.
+type Clipboard struct {
+	ctrl  input.Controller
+	copy  []string
+	paste input.Command
+}
+
+// NewClipboard finds a clipboard tool: pbcopy on macOS, otherwise wl-copy
+// on Wayland or xclip on X11.
+func NewClipboard(ctrl input.Controller) (*Clipboard, error) {
+	var candidates [][]string
+	switch {
+	case runtime.GOOS == "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		candidates = [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}}
+	default:
+		candidates = [][]string{{"xclip", "-selection", "clipboard"}}
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return newClipboard(ctrl, c), nil
+		}
+	}
+	return nil, fmt.Errorf("clipboard: %s not found", candidates[0][0])
+}
+
+func newClipboard(ctrl input.Controller, copyCmd []string) *Clipboard {
+	mod := "ctrl"
+	if runtime.GOOS == "darwin" {
+		mod = "super"
+	}
+	return &Clipboard{ctrl: ctrl, copy: copyCmd, paste: input.Command{Action: "key", Args: mod + "+v"}}
+}
+
+func (c *Clipboard) Output(ctx context.Context, text string) error {
+	cmd := exec.CommandContext(ctx, c.copy[0], c.copy[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		return &InputError{Sink: NameClipboard, Err: fmt.Errorf("%s: %w", c.copy[0], err)}
+	}
+	if err := c.ctrl.ExecuteCommands(ctx, []input.Command{c.paste}); err != nil {
+		return &InputError{Sink: NameClipboard, Err: fmt.Errorf("paste: %w", err)}
+	}
+	return nil
+}
+
+// File appends each transcript as a line to a file.
+type File struct {
+	Path string
+}
+
+func (f File) Output(ctx context.Context, text string) error {
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("file: %w", err)
+	}
+	if _, err := fmt.Fprintln(file, text); err != nil {
+		file.Close()
+		return fmt.Errorf("file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("file: %w", err)
+	}
+	return nil
+}
+
+// Writer writes each transcript as a line to w, e.g. stdout.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Output(ctx context.Context, text string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := fmt.Fprintln(w.w, text); err != nil {
+		return fmt.Errorf("stdout: %w", err)
+	}
+	return nil
+}
+
+// Command runs a shell command for each transcript with the text, followed
+// by a newline, on its stdin.
+type Command struct {
+	Command string
+}
+
+func (c Command) Output(ctx context.Context, text string) error {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Stdin = strings.NewReader(text + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := bytes.TrimSpace(out); len(msg) > 0 {
+			return fmt.Errorf("command: %w: %s", err, msg)
+		}
+		return fmt.Errorf("command: %w", err)
+	}
+	return nil
+}
+
+// HTTP posts each transcript to a URL as {"text": "..."}.
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTP(url string) *HTTP {
+	return &HTTP{URL: url, Client: &http.Client{Timeout: Timeout}}
+}
+
+func (h *HTTP) Output(ctx context.Context, text string) error {
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{text})
+	if err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("http: %s: %s", h.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richiejp/VoxInput/internal/input"
+)
+
+type fakeController struct {
+	cmds []input.Command
+	err  error
+}
+
+func (f *fakeController) ExecuteCommands(ctx context.Context, cmds []input.Command) error {
+	f.cmds = append(f.cmds, cmds...)
+	return f.err
+}
+
+func (f *fakeController) TypeText(ctx context.Context, text string) error {
+	return f.ExecuteCommands(ctx, []input.Command{{Action: "type", Args: text}})
+}
+
+func (f *fakeController) Close() error { return nil }
+
+// typed replays the commands on a line of text.
+func (f *fakeController) typed() string {
+	var line []rune
+	for _, c := range f.cmds {
+		switch {
+		case c.Action == "type":
+			line = append(line, []rune(c.Args)...)
+		case c.Args == "space":
+			line = append(line, ' ')
+		case c.Args == "backspace" && len(line) > 0:
+			line = line[:len(line)-1]
+		}
+	}
+	return string(line)
+}
+
+func TestKeyboard(t *testing.T) {
+	ctrl := &fakeController{}
+	k := NewKeyboard(ctrl)
+	ctx := context.Background()
+
+	if err := k.Output(ctx, "Hello."); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{" I", " I red", " I red it"} {
+		if err := k.Partial(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := k.Output(ctx, "I read it."); err != nil {
+		t.Fatal(err)
+	}
+	if got := ctrl.typed(); got != "Hello.I read it." {
+		t.Errorf("typed %q", got)
+	}
+
+	if err := k.Partial(ctx, "Um"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := k.Erase(ctx); err != nil || n != 2 {
+		t.Errorf("Erase = %d, %v", n, err)
+	}
+	if got := ctrl.typed(); got != "Hello.I read it." {
+		t.Errorf("typed %q after erasing", got)
+	}
+
+	ctrl.err = errors.New("dotool not found")
+	err := Multi{k}.Output(ctx, "x")
+	var inputErr *InputError
+	if !errors.As(err, &inputErr) || !strings.HasPrefix(err.Error(), "keyboard: ") {
+		t.Errorf("Output = %v, want a keyboard InputError", err)
+	}
+}
+
+func TestKeyboardUndo(t *testing.T) {
+	ctrl := &fakeController{}
+	k := NewKeyboard(ctrl)
+	ctx := context.Background()
+	erase := input.Retype("Hi.", "")
+
+	if err := k.Output(ctx, "Hi."); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Partial(ctx, "So"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Undo(ctx, erase); !errors.Is(err, ErrPartialTyped) {
+		t.Errorf("Undo with a partial typed = %v, want ErrPartialTyped", err)
+	}
+	if got := ctrl.typed(); got != "Hi.So" {
+		t.Errorf("typed %q after a refused undo", got)
+	}
+
+	if err := k.Output(ctx, "So."); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Undo(ctx, input.Retype("So.", "")); err != nil {
+		t.Fatal(err)
+	}
+	if got := ctrl.typed(); got != "Hi." {
+		t.Errorf("typed %q after undo", got)
+	}
+
+	if k.KeepPartial() {
+		t.Error("KeepPartial reported a partial typed after Output")
+	}
+	if err := k.Partial(ctx, "Um"); err != nil {
+		t.Fatal(err)
+	}
+	if !k.KeepPartial() {
+		t.Error("KeepPartial reported no partial typed")
+	}
+}
+
+func TestClipboard(t *testing.T) {
+	dir := t.TempDir()
+	clip := filepath.Join(dir, "clip")
+	ctrl := &fakeController{}
+	c := newClipboard(ctrl, []string{"sh", "-c", "cat > " + clip})
+
+	if err := c.Output(context.Background(), "Hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(clip); string(data) != "Hello world" {
+		t.Errorf("clipboard = %q", data)
+	}
+	if len(ctrl.cmds) != 1 || !strings.HasSuffix(ctrl.cmds[0].Args, "+v") {
+		t.Errorf("paste commands = %+v", ctrl.cmds)
+	}
+}
+
+func TestFileWriterCommand(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	var buf bytes.Buffer
+	piped := filepath.Join(dir, "piped")
+	m := Multi{
+		File{Path: filepath.Join(dir, "out.txt")},
+		NewWriter(&buf),
+		Command{Command: "cat >> " + piped},
+	}
+	for _, text := range []string{"one", "two"} {
+		if err := m.Output(ctx, text); err != nil {
+			t.Fatalf("Output(%q): %v", text, err)
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "out.txt")); string(data) != "one\ntwo\n" {
+		t.Errorf("file = %q", data)
+	}
+	if buf.String() != "one\ntwo\n" {
+		t.Errorf("writer = %q", buf.String())
+	}
+	if data, _ := os.ReadFile(piped); string(data) != "one\ntwo\n" {
+		t.Errorf("command stdin = %q", data)
+	}
+}
+
+func TestMultiContinuesAfterError(t *testing.T) {
+	var buf bytes.Buffer
+	m := Multi{Command{Command: "echo broken >&2; exit 3"}, NewWriter(&buf)}
+	err := m.Output(context.Background(), "text")
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Output = %v, want the command's error", err)
+	}
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		t.Errorf("Output = %v, the command sink is not an input sink", err)
+	}
+	if buf.String() != "text\n" {
+		t.Errorf("writer = %q, want the text despite the error", buf.String())
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var got struct {
+		Text string `json:"text"`
+	}
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	h := NewHTTP(srv.URL)
+	if err := h.Output(context.Background(), "Hello \"world\""); err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != "Hello \"world\"" {
+		t.Errorf("posted %q", got.Text)
+	}
+
+	status = http.StatusInternalServerError
+	if err := h.Output(context.Background(), "x"); err == nil {
+		t.Error("expected an error for a failed request")
+	}
+}
@@ -5,32 +5,16 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 )
 
-func Path() (string, error) {
-	voxDir := os.Getenv("VOXINPUT_RUNTIME_DIR")
-	if voxDir != "" {
-		p := filepath.Join(voxDir, "VoxInput.pid")
-		if _, err := os.Stat(p); err == nil {
-			return p, nil
-		}
-	}
-	defaultDir := platformDefaultRuntimeDir()
-	p := filepath.Join(defaultDir, "VoxInput.pid")
-	if _, err := os.Stat(p); err == nil {
-		return p, nil
-	}
-
-	runtimeDir := os.Getenv("VOXINPUT_RUNTIME_DIR")
-	if runtimeDir == "" {
-		runtimeDir = os.Getenv("XDG_RUNTIME_DIR")
-		if runtimeDir == "" {
-			runtimeDir = defaultDir
-		}
-	}
-
-	return filepath.Join(runtimeDir, "VoxInput.pid"), nil
+// Path returns the PID file of the named listener instance, or of the
+// default instance if instance is empty.
+func Path(instance string) (string, error) {
+	return runtimePath(fileName(instance, ".pid"))
 }
 
 func Write(path string) error {
@@ -65,16 +49,30 @@ func Read(path string) (int, error) {
 	return pid, nil
 }
 
-func StatePath() (string, error) {
+// StatePath returns the state file of the named listener instance, or of
+// the default instance if instance is empty.
+func StatePath(instance string) (string, error) {
+	return runtimePath(fileName(instance, ".state"))
+}
+
+// TokenPath returns the file holding the HTTP API token of the named
+// listener instance, or of the default instance if instance is empty.
+func TokenPath(instance string) (string, error) {
+	return runtimePath(fileName(instance, ".token"))
+}
+
+// runtimePath finds name in the runtime directories, preferring one where it
+// already exists.
+func runtimePath(name string) (string, error) {
 	voxDir := os.Getenv("VOXINPUT_RUNTIME_DIR")
 	if voxDir != "" {
-		p := filepath.Join(voxDir, "VoxInput.state")
+		p := filepath.Join(voxDir, name)
 		if _, err := os.Stat(p); err == nil {
 			return p, nil
 		}
 	}
 	defaultDir := platformDefaultRuntimeDir()
-	p := filepath.Join(defaultDir, "VoxInput.state")
+	p := filepath.Join(defaultDir, name)
 	if _, err := os.Stat(p); err == nil {
 		return p, nil
 	}
@@ -87,7 +85,65 @@ func StatePath() (string, error) {
 		}
 	}
 
-	return filepath.Join(runtimeDir, "VoxInput.state"), nil
+	return filepath.Join(runtimeDir, name), nil
+}
+
+var instanceName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateInstance checks that an instance name can be used in file names.
+func ValidateInstance(instance string) error {
+	if instance != "" && !instanceName.MatchString(instance) {
+		return fmt.Errorf("invalid instance name %q: use letters, digits, '.', '_' and '-'", instance)
+	}
+	return nil
+}
+
+// fileName returns the name of an instance's runtime file; the default
+// instance keeps the names used before instances existed.
+func fileName(instance, ext string) string {
+	if instance == "" {
+		return "VoxInput" + ext
+	}
+	return "VoxInput-" + instance + ext
+}
+
+// Instances returns the names of the instances with a PID file in one of the
+// runtime directories, sorted, with "" for the default instance. The
+// processes may no longer be running.
+func Instances() ([]string, error) {
+	dirs := []string{os.Getenv("VOXINPUT_RUNTIME_DIR"), platformDefaultRuntimeDir(), os.Getenv("XDG_RUNTIME_DIR")}
+	seen := make(map[string]bool)
+	var instances []string
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				continue
+			}
+			return nil, fmt.Errorf("pid: failed to list %s: %w", dir, err)
+		}
+		for _, e := range entries {
+			name, ok := strings.CutSuffix(e.Name(), ".pid")
+			if !ok || e.IsDir() {
+				continue
+			}
+			var instance string
+			if name != "VoxInput" {
+				if instance, ok = strings.CutPrefix(name, "VoxInput-"); !ok || ValidateInstance(instance) != nil {
+					continue
+				}
+			}
+			if !seen[instance] {
+				seen[instance] = true
+				instances = append(instances, instance)
+			}
+		}
+	}
+	sort.Strings(instances)
+	return instances, nil
 }
 
 func WriteState(path string, recording bool) error {
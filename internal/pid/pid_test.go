@@ -65,7 +65,7 @@ func TestReadStateMissingFile(t *testing.T) {
 func TestStatePath(t *testing.T) {
 	t.Setenv("VOXINPUT_RUNTIME_DIR", t.TempDir())
 
-	path, err := StatePath()
+	path, err := StatePath("")
 	if err != nil {
 		t.Fatalf("StatePath: %v", err)
 	}
@@ -74,3 +74,63 @@ func TestStatePath(t *testing.T) {
 		t.Errorf("unexpected state filename: %s", path)
 	}
 }
+
+func TestInstancePaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("VOXINPUT_RUNTIME_DIR", dir)
+
+	path, err := Path("assistant")
+	if err != nil {
+		t.Fatalf("Path: %v", err)
+	}
+	if path != filepath.Join(dir, "VoxInput-assistant.pid") {
+		t.Errorf("unexpected PID path: %s", path)
+	}
+
+	path, err = StatePath("assistant")
+	if err != nil {
+		t.Fatalf("StatePath: %v", err)
+	}
+	if path != filepath.Join(dir, "VoxInput-assistant.state") {
+		t.Errorf("unexpected state path: %s", path)
+	}
+}
+
+func TestValidateInstance(t *testing.T) {
+	for _, name := range []string{"", "assistant", "dictation-2", "a.b_c"} {
+		if err := ValidateInstance(name); err != nil {
+			t.Errorf("ValidateInstance(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"../x", "a/b", "-x", ".hidden", "with space"} {
+		if err := ValidateInstance(name); err == nil {
+			t.Errorf("ValidateInstance(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func TestInstances(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("VOXINPUT_RUNTIME_DIR", dir)
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+
+	for _, name := range []string{"VoxInput.pid", "VoxInput-dictation.pid", "VoxInput-assistant.pid", "VoxInput-assistant.state", "other.pid"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("1"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Instances()
+	if err != nil {
+		t.Fatalf("Instances: %v", err)
+	}
+	want := []string{"", "assistant", "dictation"}
+	if len(got) != len(want) {
+		t.Fatalf("Instances() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Instances()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
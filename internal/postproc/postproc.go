@@ -0,0 +1,235 @@
+// Package postproc cleans up transcripts before they are typed: filler
+// words are dropped, known terms are spelled the way we want and spacing,
+// punctuation and capitalisation are tidied.
+package postproc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Config describes a pipeline. The stages run in the order of the fields;
+// those left empty or false are skipped.
+//
+//	{
+//	  "fillers": ["um", "uh", "you know"],
+//	  "dictionary": {"vox input": "VoxInput", "local ai": "LocalAI"},
+//	  "regex": [{"pattern": "(\\d+) percent", "replace": "${1}%"}],
+//	  "normalize_spacing": true,
+//	  "capitalize": true
+//	}
+type Config struct {
+	// Fillers are words or phrases removed along with a following comma.
+	Fillers []string `json:"fillers,omitempty"`
+	// Dictionary replaces whole words or phrases, ignoring case, with the
+	// given spelling.
+	Dictionary map[string]string `json:"dictionary,omitempty"`
+	// Regex rewrites are applied in order. Replace may refer to groups
+	// as in regexp.Regexp.ReplaceAllString.
+	Regex []RegexRule `json:"regex,omitempty"`
+	// NormalizeSpacing trims the text, collapses runs of whitespace and
+	// removes space before punctuation and doubled punctuation.
+	NormalizeSpacing bool `json:"normalize_spacing,omitempty"`
+	// Capitalize upper cases the first letter of each sentence.
+	Capitalize bool `json:"capitalize,omitempty"`
+}
+
+type RegexRule struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+}
+
+// Processor transforms a transcript.
+type Processor func(text string) string
+
+// Pipeline runs processors in order.
+type Pipeline []Processor
+
+// Process returns text after every processor has been applied.
+func (p Pipeline) Process(text string) string {
+	for _, proc := range p {
+		text = proc(text)
+	}
+	return text
+}
+
+// New builds the pipeline described by c.
+func New(c Config) (Pipeline, error) {
+	var p Pipeline
+	if len(c.Fillers) > 0 {
+		p = append(p, Fillers(c.Fillers))
+	}
+	if len(c.Dictionary) > 0 {
+		p = append(p, Dictionary(c.Dictionary))
+	}
+	for i, rule := range c.Regex {
+		proc, err := Regex(rule)
+		if err != nil {
+			return nil, fmt.Errorf("regex %d: %w", i, err)
+		}
+		p = append(p, proc)
+	}
+	if c.NormalizeSpacing {
+		p = append(p, NormalizeSpacing)
+	}
+	if c.Capitalize {
+		p = append(p, Capitalize)
+	}
+	return p, nil
+}
+
+// Load builds the pipeline described by a JSON file.
+func Load(path string) (Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("postproc: %w", err)
+	}
+
+	var c Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("postproc: parse %s: %w", path, err)
+	}
+
+	p, err := New(c)
+	if err != nil {
+		return nil, fmt.Errorf("postproc: %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// isWordRune reports whether r is matched by \w. Word boundaries are only
+// added next to such characters, so terms like "C++" can be replaced.
+func isWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+// phrasePattern returns a case insensitive pattern matching phrase as a
+// whole word, with any whitespace between its words.
+func phrasePattern(phrase string) string {
+	words := strings.Fields(phrase)
+	for i, w := range words {
+		words[i] = regexp.QuoteMeta(w)
+	}
+	pattern := strings.Join(words, `\s+`)
+
+	first, _ := utf8.DecodeRuneInString(phrase)
+	last, _ := utf8.DecodeLastRuneInString(phrase)
+	if isWordRune(first) {
+		pattern = `\b` + pattern
+	}
+	if isWordRune(last) {
+		pattern += `\b`
+	}
+	return `(?i)` + pattern
+}
+
+// Dictionary replaces each key of terms, as a whole word or phrase in any
+// case, with its value. Longer keys are replaced first so "local ai
+// server" wins over "local ai".
+func Dictionary(terms map[string]string) Processor {
+	keys := make([]string, 0, len(terms))
+	for k := range terms {
+		if strings.TrimSpace(k) != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	type replacement struct {
+		re   *regexp.Regexp
+		with string
+	}
+	replacements := make([]replacement, len(keys))
+	for i, k := range keys {
+		replacements[i] = replacement{regexp.MustCompile(phrasePattern(k)), terms[k]}
+	}
+
+	return func(text string) string {
+		for _, r := range replacements {
+			text = r.re.ReplaceAllLiteralString(text, r.with)
+		}
+		return text
+	}
+}
+
+// Regex replaces every match of rule.Pattern with rule.Replace.
+func Regex(rule RegexRule) (Processor, error) {
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(text string) string {
+		return re.ReplaceAllString(text, rule.Replace)
+	}, nil
+}
+
+// Fillers removes the given words or phrases, in any case, together with
+// a comma directly after them, so "Um, so, uh, yes" with the fillers "um"
+// and "uh" becomes " so,  yes". Follow it with NormalizeSpacing to
+// tidy the gaps.
+func Fillers(fillers []string) Processor {
+	var patterns []string
+	for _, f := range fillers {
+		if strings.TrimSpace(f) != "" {
+			patterns = append(patterns, strings.TrimPrefix(phrasePattern(f), "(?i)"))
+		}
+	}
+	if len(patterns) == 0 {
+		return func(text string) string { return text }
+	}
+	// Longer fillers first, so "you know" is not cut to "you"
+	sort.Slice(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	re := regexp.MustCompile(`(?i)(?:` + strings.Join(patterns, "|") + `),?`)
+
+	return func(text string) string {
+		return re.ReplaceAllString(text, "")
+	}
+}
+
+var (
+	spaceRun          = regexp.MustCompile(`\s+`)
+	spaceBeforePunct  = regexp.MustCompile(`\s+([,.;:!?])`)
+	commaBeforeEnd    = regexp.MustCompile(`,+([.;:!?])`)
+	repeatedPunct     = regexp.MustCompile(`([,;:])[,;:]+`)
+	leadingPunct      = regexp.MustCompile(`^[,;:\s]+`)
+	sentenceStartCase = regexp.MustCompile(`(^|[.!?]\s+)(\p{Ll})`)
+)
+
+// NormalizeSpacing trims text, collapses whitespace to single spaces and
+// removes space before punctuation, punctuation at the start and commas
+// or semicolons doubled up, e.g. by removing a filler word. Text left
+// without letters or digits, such as a lone full stop, becomes empty.
+func NormalizeSpacing(text string) string {
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return ""
+	}
+	text = spaceRun.ReplaceAllString(text, " ")
+	text = spaceBeforePunct.ReplaceAllString(text, "$1")
+	text = commaBeforeEnd.ReplaceAllString(text, "$1")
+	text = repeatedPunct.ReplaceAllString(text, "$1")
+	text = leadingPunct.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+// Capitalize upper cases the first letter of the text and of each sentence
+// after a full stop, question mark or exclamation mark.
+func Capitalize(text string) string {
+	return sentenceStartCase.ReplaceAllStringFunc(text, func(m string) string {
+		r, size := utf8.DecodeLastRuneInString(m)
+		return m[:len(m)-size] + string(unicode.ToUpper(r))
+	})
+}
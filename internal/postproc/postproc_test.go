@@ -0,0 +1,161 @@
+package postproc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDictionary(t *testing.T) {
+	proc := Dictionary(map[string]string{
+		"vox input":       "VoxInput",
+		"local ai":        "LocalAI",
+		"local ai server": "LocalAI server",
+		"c++":             "C++",
+	})
+
+	tests := []struct {
+		in, want string
+	}{
+		{"I use vox input daily.", "I use VoxInput daily."},
+		{"Vox Input and VOX  INPUT", "VoxInput and VoxInput"},
+		{"start the local AI server", "start the LocalAI server"},
+		{"local ai, vox input", "LocalAI, VoxInput"},
+		{"locale aid", "locale aid"},
+		{"write c++ code", "write C++ code"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := proc(tt.in); got != tt.want {
+			t.Errorf("Dictionary(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRegex(t *testing.T) {
+	proc, err := Regex(RegexRule{Pattern: `(\d+) percent`, Replace: "${1}%"})
+	if err != nil {
+		t.Fatalf("Regex: %v", err)
+	}
+	if got, want := proc("up 20 percent, down 5 percent"), "up 20%, down 5%"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := Regex(RegexRule{Pattern: "("}); err == nil {
+		t.Error("invalid pattern compiled")
+	}
+}
+
+func TestFillers(t *testing.T) {
+	proc := Fillers([]string{"um", "uh", "you know"})
+
+	tests := []struct {
+		in, want string
+	}{
+		{"Um, so, uh, yes", " so,  yes"},
+		{"it's, you know, fine", "it's,  fine"},
+		{"umbrella and uhm", "umbrella and uhm"},
+		{"UM", ""},
+	}
+	for _, tt := range tests {
+		if got := proc(tt.in); got != tt.want {
+			t.Errorf("Fillers(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeSpacing(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"  hello   world  ", "hello world"},
+		{"hello , world .", "hello, world."},
+		{"yes,.", "yes."},
+		{"so,,  yes", "so, yes"},
+		{", and then", "and then"},
+		{".NET rocks", ".NET rocks"},
+		{"3,5 and 1.5", "3,5 and 1.5"},
+		{"line\none", "line one"},
+		{" . ", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeSpacing(tt.in); got != tt.want {
+			t.Errorf("NormalizeSpacing(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCapitalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"hello. how are you? fine! ok", "Hello. How are you? Fine! Ok"},
+		{"übung macht den meister", "Übung macht den meister"},
+		{"version 1.5 is out", "Version 1.5 is out"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Capitalize(tt.in); got != tt.want {
+			t.Errorf("Capitalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p, err := New(Config{
+		Fillers:          []string{"um", "uh"},
+		Dictionary:       map[string]string{"vox input": "VoxInput"},
+		Regex:            []RegexRule{{Pattern: `\bteh\b`, Replace: "the"}},
+		NormalizeSpacing: true,
+		Capitalize:       true,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		in, want string
+	}{
+		{" um, vox input is teh best. uh, really .", "VoxInput is the best. Really."},
+		{"Um.", ""},
+		{"nothing to do", "Nothing to do"},
+	}
+	for _, tt := range tests {
+		if got := p.Process(tt.in); got != tt.want {
+			t.Errorf("Process(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got := Pipeline(nil).Process(" as is "); got != " as is " {
+		t.Errorf("empty pipeline changed text to %q", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	p, err := Load(write("ok.json", `{"dictionary": {"local ai": "LocalAI"}, "capitalize": true}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := p.Process("local ai works"), "LocalAI works"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for name, content := range map[string]string{
+		"bad_regex.json": `{"regex": [{"pattern": "(", "replace": ""}]}`,
+		"unknown.json":   `{"capitalise": true}`,
+		"syntax.json":    `{`,
+	} {
+		if _, err := Load(write(name, content)); err == nil {
+			t.Errorf("Load(%s) succeeded, want error", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Package segment writes timestamped transcript segments as plain text,
+// SubRip (SRT), WebVTT or JSON lines.
+package segment
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The output formats.
+const (
+	FormatText = "text"
+	FormatSRT  = "srt"
+	FormatVTT  = "vtt"
+	FormatJSON = "json"
+)
+
+// Formats lists the output formats in the order they are documented.
+var Formats = []string{FormatText, FormatSRT, FormatVTT, FormatJSON}
+
+// Segment is the transcript of a stretch of audio, with offsets from the
+// start of the audio.
+type Segment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Writer writes segments in one of the formats.
+type Writer struct {
+	w      io.Writer
+	format string
+	// n is the number of segments written so far
+	n int
+}
+
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	switch format {
+	case FormatText, FormatSRT, FormatVTT, FormatJSON:
+		return &Writer{w: w, format: format}, nil
+	}
+	return nil, fmt.Errorf("segment: unknown format %q, want one of %s", format, strings.Join(Formats, ", "))
+}
+
+func (w *Writer) Write(s Segment) error {
+	text := strings.TrimSpace(s.Text)
+	var err error
+	switch w.format {
+	case FormatText:
+		_, err = fmt.Fprintf(w.w, "[%s --> %s] %s\n", timestamp(s.Start, '.'), timestamp(s.End, '.'), text)
+	case FormatSRT:
+		_, err = fmt.Fprintf(w.w, "%d\n%s --> %s\n%s\n\n", w.n+1, timestamp(s.Start, ','), timestamp(s.End, ','), text)
+	case FormatVTT:
+		if w.n == 0 {
+			if _, err = io.WriteString(w.w, "WEBVTT\n\n"); err != nil {
+				break
+			}
+		}
+		_, err = fmt.Fprintf(w.w, "%s --> %s\n%s\n\n", timestamp(s.Start, '.'), timestamp(s.End, '.'), text)
+	case FormatJSON:
+		var line []byte
+		line, err = json.Marshal(struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+		}{s.Start.Seconds(), s.End.Seconds(), text})
+		if err == nil {
+			_, err = fmt.Fprintf(w.w, "%s\n", line)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("segment: %w", err)
+	}
+	w.n++
+	return nil
+}
+
+// timestamp formats d as hh:mm:ss followed by sep and milliseconds, which
+// is a comma in SRT and a full stop elsewhere.
+func timestamp(d time.Duration, sep byte) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
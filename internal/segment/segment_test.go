@@ -0,0 +1,41 @@
+package segment
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	segments := []Segment{
+		{Start: 1200 * time.Millisecond, End: 3400 * time.Millisecond, Text: " Hello world."},
+		{Start: time.Hour + 2*time.Minute + 3*time.Second + 5*time.Millisecond, End: time.Hour + 2*time.Minute + 4*time.Second, Text: "Bye."},
+	}
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{FormatText, "[00:00:01.200 --> 00:00:03.400] Hello world.\n[01:02:03.005 --> 01:02:04.000] Bye.\n"},
+		{FormatSRT, "1\n00:00:01,200 --> 00:00:03,400\nHello world.\n\n2\n01:02:03,005 --> 01:02:04,000\nBye.\n\n"},
+		{FormatVTT, "WEBVTT\n\n00:00:01.200 --> 00:00:03.400\nHello world.\n\n01:02:03.005 --> 01:02:04.000\nBye.\n\n"},
+		{FormatJSON, "{\"start\":1.2,\"end\":3.4,\"text\":\"Hello world.\"}\n{\"start\":3723.005,\"end\":3724,\"text\":\"Bye.\"}\n"},
+	} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, tc.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range segments {
+			if err := w.Write(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if buf.String() != tc.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tc.format, buf.String(), tc.want)
+		}
+	}
+
+	if _, err := NewWriter(&bytes.Buffer{}, "csv"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -2,7 +2,10 @@ package semver
 
 // Copied from PremAI/Ayup
 
-import "fmt"
+import (
+	"bytes"
+	"fmt"
+)
 
 type Version struct {
 	major int
@@ -15,6 +18,18 @@ func (s Version) IsZero() bool {
 	return s.major == 0 && s.minor == 0 && s.patch == 0
 }
 
+func (s Version) Major() int { return s.major }
+func (s Version) Minor() int { return s.minor }
+func (s Version) Patch() int { return s.patch }
+
+func (s Version) String() string {
+	v := fmt.Sprintf("%d.%d.%d", s.major, s.minor, s.patch)
+	if label := bytes.TrimSpace(s.label); len(label) > 0 {
+		v += "-" + string(label)
+	}
+	return v
+}
+
 func Parse(bs []byte) (Version, error) {
 	v := Version{}
 	dotCount := 0
@@ -66,16 +81,25 @@ func Parse(bs []byte) (Version, error) {
 	return v, nil
 }
 
-// For now this is just used for validation
-// var currentVersion Version
+var currentVersion Version
 
-func SetVersion(bs []byte) (err error) {
-	// if !currentVersion.IsZero() {
-	// 	return fmt.Errorf("Version can only be set once")
-	// }
+func SetVersion(bs []byte) error {
+	if !currentVersion.IsZero() {
+		return fmt.Errorf("Version can only be set once")
+	}
+
+	v, err := Parse(bs)
+	if err != nil {
+		return err
+	}
+	currentVersion = v
 
-	_, err = Parse(bs)
+	return nil
+}
 
-	return err
+// Current returns the version passed to SetVersion, or the zero version if
+// it has not been set.
+func Current() Version {
+	return currentVersion
 }
 
@@ -2,21 +2,32 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/richiejp/VoxInput/internal/ipc"
 )
 
+// renderPartial renders the transcript of the speech so far.
+func renderPartial(text string) string {
+	return fmt.Sprintf("%s %s", userStyle.Render("You:"), statusStyle.Render(text+"…"))
+}
+
 func renderChatEvent(e ipc.Event) string {
 	ts := time.UnixMilli(e.Ts).Format("15:04:05")
 
 	switch e.Kind {
 	case ipc.EventTranscript:
 		if e.IsUser {
-			return fmt.Sprintf("%s %s %s",
+			line := fmt.Sprintf("%s %s %s",
 				logTimestampStyle.Render(ts),
 				userStyle.Render("You:"),
 				e.Text)
+			if e.Raw != "" {
+				// Show what was heard below what was typed
+				line += "\n" + strings.Repeat(" ", len(ts)) + " " + statusStyle.Render("Raw: "+e.Raw)
+			}
+			return line
 		}
 		return fmt.Sprintf("%s %s %s",
 			logTimestampStyle.Render(ts),
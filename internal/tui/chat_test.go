@@ -18,6 +18,25 @@ func TestRenderTranscriptEvent(t *testing.T) {
 	}
 }
 
+func TestRenderCorrectedTranscriptEvent(t *testing.T) {
+	e := ipc.Event{Kind: ipc.EventTranscript, Ts: 1000, Text: "Run LocalAI.", Raw: "run local eye", IsUser: true}
+	lines := strings.Split(renderChatEvent(e), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", lines)
+	}
+	if !strings.Contains(lines[0], "Run LocalAI.") {
+		t.Errorf("expected the corrected text first, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "Raw: run local eye") {
+		t.Errorf("expected the raw text second, got %q", lines[1])
+	}
+
+	e.Raw = ""
+	if rendered := renderChatEvent(e); strings.Contains(rendered, "\n") {
+		t.Errorf("expected one line without a raw transcript, got %q", rendered)
+	}
+}
+
 func TestRenderStatusEvent(t *testing.T) {
 	e := ipc.Event{Kind: ipc.EventStatus, Ts: 1000, Text: "Listening..."}
 	rendered := renderChatEvent(e)
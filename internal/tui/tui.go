@@ -38,18 +38,42 @@ type Model struct {
 	height     int
 	recording  bool
 	quitting   bool
+	// partial is the live transcript of the current utterance, shown
+	// below the chat until its transcript arrives.
+	partial string
+	// pending maps the IDs of commands awaiting a reply to their kind.
+	pending map[string]ipc.CommandKind
 }
 
 func NewModel(client *ipc.Client) Model {
 	chatView := viewport.New(80, 20)
 	logView := viewport.New(80, 20)
-	return Model{
+	m := Model{
 		client:   client,
 		chatView: chatView,
 		logView:  logView,
 		width:    80,
 		height:   24,
 	}
+	if client != nil {
+		if warning := helloWarning(client.Hello()); warning != "" {
+			m.appendChat(errorStyle.Render(warning))
+		}
+	}
+	return m
+}
+
+// helloWarning returns a warning if the daemon did not send a hello or
+// speaks an incompatible protocol, which happens when the TUI connects to
+// a daemon from a different build.
+func helloWarning(h *ipc.Hello) string {
+	if h == nil {
+		return "Warning: the daemon did not identify itself; it is probably an older version and some features may not work"
+	}
+	if err := h.CheckProtocol(); err != nil {
+		return "Warning: incompatible daemon: " + err.Error()
+	}
+	return ""
 }
 
 func (m Model) Init() tea.Cmd {
@@ -77,15 +101,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, tea.Quit
 		case "r":
-			if m.client != nil {
-				m.client.SendCommand(ipc.Command{Kind: ipc.CommandRecord})
-				m.recording = true
-			}
+			m.sendCommand(ipc.CommandRecord)
 		case "s":
-			if m.client != nil {
-				m.client.SendCommand(ipc.Command{Kind: ipc.CommandStop})
-				m.recording = false
-			}
+			m.sendCommand(ipc.CommandStop)
 		case "tab":
 			if m.activeTab == TabChat {
 				m.activeTab = TabLog
@@ -115,20 +133,32 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.logEntries = appendBounded(m.logEntries, line)
 			m.logView.SetContent(strings.Join(m.logEntries, "\n"))
 			m.logView.GotoBottom()
+		case ipc.EventReply:
+			kind, ok := m.pending[e.ID]
+			if !ok {
+				break
+			}
+			delete(m.pending, e.ID)
+			m.recording = e.Recording
+			if !e.OK {
+				m.appendChat(errorStyle.Render(fmt.Sprintf("%s failed: %s", kind, e.Error)))
+			}
 		case ipc.EventStatus:
 			m.recording = e.Recording
-			rendered := renderChatEvent(e)
-			if rendered != "" {
-				m.chatLog = appendBounded(m.chatLog, rendered)
-				m.chatView.SetContent(strings.Join(m.chatLog, "\n"))
-				m.chatView.GotoBottom()
+			if rendered := renderChatEvent(e); rendered != "" {
+				m.appendChat(rendered)
 			}
+		case ipc.EventPartial:
+			m.partial = e.Text
+			m.refreshChat()
 		default:
-			rendered := renderChatEvent(e)
-			if rendered != "" {
-				m.chatLog = appendBounded(m.chatLog, rendered)
-				m.chatView.SetContent(strings.Join(m.chatLog, "\n"))
-				m.chatView.GotoBottom()
+			if e.Kind == ipc.EventTranscript && e.IsUser {
+				m.partial = ""
+			}
+			if rendered := renderChatEvent(e); rendered != "" {
+				m.appendChat(rendered)
+			} else {
+				m.refreshChat()
 			}
 		}
 		return m, readEvent(m.client)
@@ -149,9 +179,40 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m *Model) updateViewports() {
-	m.chatView.SetContent(strings.Join(m.chatLog, "\n"))
+// sendCommand sends a command to the daemon and remembers it until the
+// reply arrives; the recording state is only updated from the reply.
+func (m *Model) sendCommand(kind ipc.CommandKind) {
+	if m.client == nil {
+		return
+	}
+	cmd := m.client.NewCommand(kind)
+	if err := m.client.SendCommand(cmd); err != nil {
+		m.appendChat(errorStyle.Render(fmt.Sprintf("%s failed: %v", kind, err)))
+		return
+	}
+	if m.pending == nil {
+		m.pending = make(map[string]ipc.CommandKind)
+	}
+	m.pending[cmd.ID] = kind
+}
+
+func (m *Model) appendChat(line string) {
+	m.chatLog = appendBounded(m.chatLog, line)
+	m.refreshChat()
+}
+
+// refreshChat shows the chat log followed by the partial transcript.
+func (m *Model) refreshChat() {
+	content := strings.Join(m.chatLog, "\n")
+	if m.partial != "" {
+		content += "\n" + renderPartial(m.partial)
+	}
+	m.chatView.SetContent(content)
 	m.chatView.GotoBottom()
+}
+
+func (m *Model) updateViewports() {
+	m.refreshChat()
 	m.logView.SetContent(strings.Join(m.logEntries, "\n"))
 	m.logView.GotoBottom()
 }
@@ -196,6 +257,14 @@ func (m Model) View() string {
 	if m.recording {
 		state = "recording"
 	}
+	for _, kind := range m.pending {
+		switch kind {
+		case ipc.CommandRecord:
+			state = "starting"
+		case ipc.CommandStop:
+			state = "stopping"
+		}
+	}
 	hints := "r:record  s:stop  Tab:switch  q:quit"
 	status := fmt.Sprintf(" [%s]  %s", state, hints)
 	b.WriteString(statusBarStyle.Render(status))
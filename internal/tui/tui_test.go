@@ -78,6 +78,37 @@ func TestModelReceiveTranscriptEvent(t *testing.T) {
 	}
 }
 
+func TestModelReceivePartialEvent(t *testing.T) {
+	m := newTestModel()
+	m2, _ := m.Update(ipcEventMsg(ipc.Event{
+		Kind:   ipc.EventPartial,
+		Ts:     2000,
+		Text:   "Hello wor",
+		IsUser: true,
+	}))
+	model := m2.(Model)
+	if len(model.chatLog) != 0 {
+		t.Errorf("partial transcripts should not appear in chatLog, got %d", len(model.chatLog))
+	}
+	if model.partial != "Hello wor" {
+		t.Errorf("expected partial %q, got %q", "Hello wor", model.partial)
+	}
+
+	m3, _ := model.Update(ipcEventMsg(ipc.Event{
+		Kind:   ipc.EventTranscript,
+		Ts:     3000,
+		Text:   "Hello world",
+		IsUser: true,
+	}))
+	model = m3.(Model)
+	if model.partial != "" {
+		t.Errorf("expected the transcript to clear the partial, got %q", model.partial)
+	}
+	if len(model.chatLog) != 1 {
+		t.Fatalf("expected 1 chatLog entry, got %d", len(model.chatLog))
+	}
+}
+
 func TestModelReceiveLogEvent(t *testing.T) {
 	m := newTestModel()
 	m2, _ := m.Update(ipcEventMsg(ipc.Event{
@@ -130,3 +161,58 @@ func TestModelView(t *testing.T) {
 		t.Error("expected 'idle' in status bar")
 	}
 }
+
+func TestModelReceiveReply(t *testing.T) {
+	m := newTestModel()
+	m.pending = map[string]ipc.CommandKind{"1": ipc.CommandRecord}
+	if !strings.Contains(m.View(), "starting") {
+		t.Error("expected 'starting' in status bar while record is pending")
+	}
+
+	m2, _ := m.Update(ipcEventMsg(ipc.Event{
+		Kind:      ipc.EventReply,
+		ID:        "1",
+		OK:        true,
+		Recording: true,
+	}))
+	model := m2.(Model)
+	if !model.recording {
+		t.Error("expected recording=true after successful reply")
+	}
+	if len(model.pending) != 0 {
+		t.Errorf("expected no pending commands, got %d", len(model.pending))
+	}
+	if len(model.chatLog) != 0 {
+		t.Errorf("successful replies should not appear in chatLog, got %d", len(model.chatLog))
+	}
+}
+
+func TestModelReceiveErrorReply(t *testing.T) {
+	m := newTestModel()
+	m.pending = map[string]ipc.CommandKind{"1": ipc.CommandRecord}
+
+	m2, _ := m.Update(ipcEventMsg(ipc.Event{
+		Kind:  ipc.EventReply,
+		ID:    "1",
+		Error: "failed to start session: connection refused",
+	}))
+	model := m2.(Model)
+	if model.recording {
+		t.Error("expected recording=false after failed reply")
+	}
+	if len(model.chatLog) != 1 || !strings.Contains(model.chatLog[0], "connection refused") {
+		t.Errorf("expected error in chatLog, got %q", model.chatLog)
+	}
+}
+
+func TestHelloWarning(t *testing.T) {
+	if w := helloWarning(nil); w == "" {
+		t.Error("expected a warning when there is no hello")
+	}
+	if w := helloWarning(&ipc.Hello{Protocol: ipc.ProtocolVersion}); w != "" {
+		t.Errorf("expected no warning for the same protocol, got %q", w)
+	}
+	if w := helloWarning(&ipc.Hello{Protocol: "99.0.0", Version: "9.0.0"}); !strings.Contains(w, "incompatible") {
+		t.Errorf("expected incompatible warning, got %q", w)
+	}
+}
@@ -0,0 +1,100 @@
+// Package undo remembers the text typed for recent utterances, so it can be
+// erased with BackSpace when it went into the wrong window.
+package undo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/input"
+)
+
+// Depth is how many utterances are remembered.
+const Depth = 10
+
+var (
+	ErrNothing      = errors.New("nothing to undo")
+	ErrExpired      = errors.New("the text was typed too long ago")
+	ErrTooLong      = errors.New("the text is too long to erase")
+	ErrFocusChanged = errors.New("the focused window changed since the text was typed")
+)
+
+// Limits make Undo refuse when erasing could delete something other than
+// the typed text.
+type Limits struct {
+	// MaxChars is the most characters erased at once, unlimited if zero.
+	MaxChars int
+	// MaxAge is how long after the last typing or undo the text may be
+	// erased, unlimited if zero.
+	MaxAge time.Duration
+}
+
+type typed struct {
+	text  string
+	focus string
+}
+
+// Buffer is the text typed for the most recent utterances, in the order it
+// was typed. It is safe for concurrent use.
+type Buffer struct {
+	mu    sync.Mutex
+	typed []typed
+	// last is when text was last typed or erased
+	last time.Time
+	now  func() time.Time
+}
+
+func New() *Buffer {
+	return &Buffer{now: time.Now}
+}
+
+// Typed records text typed for an utterance and focus, which identifies the
+// window it was typed into or is empty if unknown.
+func (b *Buffer) Typed(text, focus string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.typed = append(b.typed, typed{text: text, focus: focus})
+	if len(b.typed) > Depth {
+		b.typed = b.typed[len(b.typed)-Depth:]
+	}
+	b.last = b.now()
+}
+
+// Clear forgets the typed text, e.g. after pressing keys which may have
+// moved the cursor.
+func (b *Buffer) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.typed = nil
+}
+
+// Undo returns the commands which erase the text typed for the last
+// utterance and forgets it, so the next call erases the one before. It
+// returns the erased text too. focus identifies the window focused now; it
+// must be the one the text was typed into. When refusing, the text is kept.
+func (b *Buffer) Undo(focus string, limits Limits) ([]input.Command, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.typed) == 0 {
+		return nil, "", ErrNothing
+	}
+	t := b.typed[len(b.typed)-1]
+	if age := b.now().Sub(b.last); limits.MaxAge > 0 && age > limits.MaxAge {
+		return nil, "", fmt.Errorf("%w (%s ago, the limit is %s)", ErrExpired, age.Round(time.Second), limits.MaxAge)
+	}
+	if focus != t.focus {
+		return nil, "", ErrFocusChanged
+	}
+	cmds := input.Retype(t.text, "")
+	if limits.MaxChars > 0 && len(cmds) > limits.MaxChars {
+		return nil, "", fmt.Errorf("%w (%d characters, the limit is %d)", ErrTooLong, len(cmds), limits.MaxChars)
+	}
+
+	b.typed = b.typed[:len(b.typed)-1]
+	b.last = b.now()
+	return cmds, t.text, nil
+}
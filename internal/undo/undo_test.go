@@ -0,0 +1,78 @@
+package undo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestBuffer(now *time.Time) *Buffer {
+	b := New()
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestUndo(t *testing.T) {
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	b := newTestBuffer(&now)
+
+	b.Typed("Hello world.", "win1")
+	b.Typed("How  are you?", "win1")
+
+	cmds, text, err := b.Undo("win1", Limits{})
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if text != "How  are you?" || len(cmds) != len("How are you?") {
+		t.Errorf("Undo = %d commands for %q", len(cmds), text)
+	}
+	for _, c := range cmds {
+		if c.Action != "key" || c.Args != "backspace" {
+			t.Fatalf("unexpected command %+v", c)
+		}
+	}
+
+	if _, text, err = b.Undo("win1", Limits{}); err != nil || text != "Hello world." {
+		t.Errorf("second Undo = %q, %v", text, err)
+	}
+	if _, _, err = b.Undo("win1", Limits{}); !errors.Is(err, ErrNothing) {
+		t.Errorf("Undo with nothing typed: %v", err)
+	}
+}
+
+func TestUndoRefuses(t *testing.T) {
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	b := newTestBuffer(&now)
+	b.Typed("Hello world.", "win1")
+
+	if _, _, err := b.Undo("win2", Limits{}); !errors.Is(err, ErrFocusChanged) {
+		t.Errorf("Undo in another window: %v", err)
+	}
+	if _, _, err := b.Undo("win1", Limits{MaxChars: 5}); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Undo over the character limit: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, _, err := b.Undo("win1", Limits{MaxAge: 30 * time.Second}); !errors.Is(err, ErrExpired) {
+		t.Errorf("Undo after the time limit: %v", err)
+	}
+
+	// Refusing keeps the text
+	if _, text, err := b.Undo("win1", Limits{}); err != nil || text != "Hello world." {
+		t.Errorf("Undo = %q, %v", text, err)
+	}
+}
+
+func TestClearAndDepth(t *testing.T) {
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	b := newTestBuffer(&now)
+	for range Depth + 5 {
+		b.Typed("word", "")
+	}
+	if len(b.typed) != Depth {
+		t.Errorf("remembered %d utterances, want %d", len(b.typed), Depth)
+	}
+	b.Clear()
+	if _, _, err := b.Undo("", Limits{}); !errors.Is(err, ErrNothing) {
+		t.Errorf("Undo after Clear: %v", err)
+	}
+}
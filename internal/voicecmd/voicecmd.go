@@ -0,0 +1,169 @@
+// Package voicecmd recognises spoken editing commands such as "new line" or
+// "delete that" in transcripts and turns them into input commands.
+//
+// An utterance is only treated as a command when all of it is a command
+// phrase, ignoring case and punctuation, so "I drew a new line" is still
+// typed as text while "New line." presses enter.
+package voicecmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"unicode"
+
+	"github.com/richiejp/VoxInput/internal/input"
+)
+
+// Entry is a voice command: the phrases which trigger it and either the
+// input commands to run or, with Delete, erasing the text typed for the
+// previous utterance.
+type Entry struct {
+	Phrases  []string        `json:"phrases"`
+	Commands []input.Command `json:"commands,omitempty"`
+	Delete   bool            `json:"delete,omitempty"`
+}
+
+// Table maps a language, as given by the lang setting, to its commands.
+//
+//	{
+//	  "en": [
+//	    {"phrases": ["send it"], "commands": [{"action": "key", "args": "ctrl+enter"}]},
+//	    {"phrases": ["scratch that"], "delete": true}
+//	  ]
+//	}
+type Table map[string][]Entry
+
+// shortcut returns the key command for the platform's shortcut modifier
+// with key, e.g. ctrl+z on Linux and command+z on macOS.
+func shortcut(key string) input.Command {
+	mod := "ctrl"
+	if runtime.GOOS == "darwin" {
+		mod = "super"
+	}
+	return input.Command{Action: "key", Args: mod + "+" + key}
+}
+
+func key(name string) input.Command {
+	return input.Command{Action: "key", Args: name}
+}
+
+// Default is the built-in command table.
+var Default = Table{
+	"en": {
+		{Phrases: []string{"new line"}, Commands: []input.Command{key("enter")}},
+		{Phrases: []string{"new paragraph"}, Commands: []input.Command{key("enter"), key("enter")}},
+		{Phrases: []string{"press enter", "press return"}, Commands: []input.Command{key("enter")}},
+		{Phrases: []string{"press tab"}, Commands: []input.Command{key("tab")}},
+		{Phrases: []string{"press escape"}, Commands: []input.Command{key("escape")}},
+		{Phrases: []string{"select all"}, Commands: []input.Command{shortcut("a")}},
+		{Phrases: []string{"undo that"}, Commands: []input.Command{shortcut("z")}},
+		{Phrases: []string{"redo that"}, Commands: []input.Command{shortcut("shift+z")}},
+		{Phrases: []string{"delete that", "scratch that"}, Delete: true},
+	},
+	"de": {
+		{Phrases: []string{"neue zeile"}, Commands: []input.Command{key("enter")}},
+		{Phrases: []string{"neuer absatz"}, Commands: []input.Command{key("enter"), key("enter")}},
+		{Phrases: []string{"drücke enter", "eingabetaste"}, Commands: []input.Command{key("enter")}},
+		{Phrases: []string{"drücke tab"}, Commands: []input.Command{key("tab")}},
+		{Phrases: []string{"drücke escape"}, Commands: []input.Command{key("escape")}},
+		{Phrases: []string{"alles auswählen"}, Commands: []input.Command{shortcut("a")}},
+		{Phrases: []string{"rückgängig"}, Commands: []input.Command{shortcut("z")}},
+		{Phrases: []string{"wiederholen"}, Commands: []input.Command{shortcut("shift+z")}},
+		{Phrases: []string{"lösch das", "das löschen"}, Delete: true},
+	},
+}
+
+// Load reads a command table from a JSON file.
+func Load(path string) (Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("voice commands: %w", err)
+	}
+
+	var t Table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("voice commands: parse %s: %w", path, err)
+	}
+	for lang, entries := range t {
+		for i, e := range entries {
+			if len(e.Phrases) == 0 {
+				return nil, fmt.Errorf("voice commands: %s entry %d has no phrases", lang, i)
+			}
+			if e.Delete == (len(e.Commands) > 0) {
+				return nil, fmt.Errorf("voice commands: %s entry %d needs either commands or delete", lang, i)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// Merge returns t with the entries of other added. Where both have the same
+// phrase for a language, other's entry is used.
+func (t Table) Merge(other Table) Table {
+	out := make(Table, len(t)+len(other))
+	for lang, entries := range t {
+		out[lang] = entries
+	}
+	for lang, entries := range other {
+		out[lang] = append(append([]Entry(nil), entries...), out[lang]...)
+	}
+	return out
+}
+
+// Matcher finds the command for an utterance in one language.
+type Matcher struct {
+	phrases map[string]Entry
+}
+
+// Matcher returns the matcher for lang, which is looked up as given and
+// then by its first two letters, so "en-GB" uses the "en" commands. An
+// empty lang uses English. It returns nil if there are no commands for
+// lang.
+func (t Table) Matcher(lang string) *Matcher {
+	lang = strings.ToLower(lang)
+	if lang == "" {
+		lang = "en"
+	}
+	entries, ok := t[lang]
+	if !ok && len(lang) > 2 {
+		entries, ok = t[lang[:2]]
+	}
+	if !ok {
+		return nil
+	}
+
+	m := &Matcher{phrases: make(map[string]Entry)}
+	for _, e := range entries {
+		for _, p := range e.Phrases {
+			// Earlier entries take precedence
+			if n := normalize(p); n != "" {
+				if _, dup := m.phrases[n]; !dup {
+					m.phrases[n] = e
+				}
+			}
+		}
+	}
+	return m
+}
+
+// Match returns the command if all of text is one of its phrases.
+func (m *Matcher) Match(text string) (Entry, bool) {
+	e, ok := m.phrases[normalize(text)]
+	return e, ok
+}
+
+// normalize lower cases text and reduces it to its words, separated by
+// single spaces.
+func normalize(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	for i, w := range words {
+		words[i] = strings.Trim(w, "'")
+	}
+	return strings.Join(words, " ")
+}
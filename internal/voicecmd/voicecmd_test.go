@@ -0,0 +1,127 @@
+package voicecmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	m := Default.Matcher("en")
+	if m == nil {
+		t.Fatal("no English matcher")
+	}
+
+	tests := []struct {
+		text    string
+		want    string
+		wantDel bool
+		ok      bool
+	}{
+		{"new line", "enter", false, true},
+		{" New line.", "enter", false, true},
+		{"NEW, LINE!", "enter", false, true},
+		{"Delete that.", "", true, true},
+		{"Scratch that", "", true, true},
+		{"I drew a new line.", "", false, false},
+		{"new line please", "", false, false},
+		{"", "", false, false},
+	}
+
+	for _, tt := range tests {
+		e, ok := m.Match(tt.text)
+		if ok != tt.ok {
+			t.Errorf("Match(%q) ok = %v, want %v", tt.text, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if e.Delete != tt.wantDel {
+			t.Errorf("Match(%q) delete = %v, want %v", tt.text, e.Delete, tt.wantDel)
+		}
+		if tt.want != "" && (len(e.Commands) == 0 || e.Commands[0].Args != tt.want) {
+			t.Errorf("Match(%q) commands = %v, want key %s", tt.text, e.Commands, tt.want)
+		}
+	}
+}
+
+func TestMatcherLang(t *testing.T) {
+	tests := []struct {
+		lang string
+		ok   bool
+	}{
+		{"", true},
+		{"en", true},
+		{"EN", true},
+		{"en-GB", true},
+		{"de", true},
+		{"xx", false},
+	}
+	for _, tt := range tests {
+		if m := Default.Matcher(tt.lang); (m != nil) != tt.ok {
+			t.Errorf("Matcher(%q) = %v, want found %v", tt.lang, m, tt.ok)
+		}
+	}
+
+	if _, ok := Default.Matcher("de").Match("Neue Zeile."); !ok {
+		t.Error(`German matcher did not match "Neue Zeile."`)
+	}
+}
+
+func TestLoadAndMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.json")
+	err := os.WriteFile(path, []byte(`{
+		"en": [
+			{"phrases": ["send it"], "commands": [{"action": "key", "args": "ctrl+enter"}]},
+			{"phrases": ["new line"], "commands": [{"action": "key", "args": "shift+enter"}]}
+		],
+		"fr": [
+			{"phrases": ["efface ça"], "delete": true}
+		]
+	}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	table := Default.Merge(file)
+
+	m := table.Matcher("en")
+	if e, ok := m.Match("Send it."); !ok || e.Commands[0].Args != "ctrl+enter" {
+		t.Errorf("send it = %v, %v", e, ok)
+	}
+	if e, ok := m.Match("new line"); !ok || e.Commands[0].Args != "shift+enter" {
+		t.Errorf("new line = %v, %v, want the file's entry", e, ok)
+	}
+	if _, ok := m.Match("select all"); !ok {
+		t.Error("built-in select all was lost by the merge")
+	}
+	if e, ok := table.Matcher("fr").Match("Efface ça !"); !ok || !e.Delete {
+		t.Errorf("efface ça = %v, %v", e, ok)
+	}
+	if len(Default["en"]) != 9 {
+		t.Errorf("Merge modified Default: %d English entries", len(Default["en"]))
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no phrases":   `{"en": [{"delete": true}]}`,
+		"no action":    `{"en": [{"phrases": ["x"]}]}`,
+		"both actions": `{"en": [{"phrases": ["x"], "delete": true, "commands": [{"action": "key", "args": "a"}]}]}`,
+		"not json":     `{`,
+	}
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), "commands.json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: Load succeeded, want error", name)
+		}
+	}
+}
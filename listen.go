@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -22,11 +23,18 @@ import (
 	"github.com/gen2brain/malgo"
 
 	"github.com/richiejp/VoxInput/internal/audio"
+	"github.com/richiejp/VoxInput/internal/codeword"
+	"github.com/richiejp/VoxInput/internal/correction"
+	"github.com/richiejp/VoxInput/internal/dbussvc"
 	"github.com/richiejp/VoxInput/internal/gui"
 	"github.com/richiejp/VoxInput/internal/input"
 	"github.com/richiejp/VoxInput/internal/ipc"
 	"github.com/richiejp/VoxInput/internal/localvqe"
+	"github.com/richiejp/VoxInput/internal/output"
 	"github.com/richiejp/VoxInput/internal/pid"
+	"github.com/richiejp/VoxInput/internal/postproc"
+	"github.com/richiejp/VoxInput/internal/undo"
+	"github.com/richiejp/VoxInput/internal/voicecmd"
 )
 
 // playbackJitterMs is the pre-roll the playback path requires before unblocking.
@@ -52,26 +60,6 @@ func (w *ipcLogWriter) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
-func getPrefixedEnv(prefixes []string, name string, fallback string) (val string) {
-	for _, p := range prefixes {
-		var n string
-		if p == "" {
-			n = name
-		} else {
-			n = p + "_" + name
-		}
-		if val = os.Getenv(n); val != "" {
-			return val
-		}
-	}
-
-	return fallback
-}
-
-func getOpenaiEnv(name string, fallback string) string {
-	return getPrefixedEnv([]string{"VOXINPUT", "OPENAI"}, name, fallback)
-}
-
 func waitForSessionUpdated(ctx context.Context, conn *openairt.Conn) error {
 	for {
 		msg, err := conn.ReadMessage(ctx)
@@ -115,6 +103,7 @@ const (
 
 type ListenConfig struct {
 	PIDPath              string
+	StatePath            string
 	APIKey               string
 	HTTPAPIBase          string
 	WSAPIBase            string
@@ -124,7 +113,25 @@ type ListenConfig struct {
 	UI                   gui.StatusSink
 	CaptureDevice        string
 	OutputFile           string
+	Output               string
+	OutputCommand        string
+	OutputURL            string
 	Prompt               string
+	CodeWordStart        string
+	CodeWordStop         string
+	VoiceCommands        bool
+	VoiceCommandsFile    string
+	TypePartial          bool
+	History              bool
+	HistoryFile          string
+	UndoMaxChars         int
+	UndoTimeout          time.Duration
+	UndoFocusCommand     string
+	PostprocessFile      string
+	Correction           bool
+	CorrectionModel      string
+	CorrectionGlossary   string
+	CorrectionTimeout    time.Duration
 	Mode                 string
 	AssistantModel       string
 	AssistantVoice       string
@@ -144,8 +151,13 @@ type ListenConfig struct {
 	AECNoiseGate         bool
 	AECNoiseGateDBFS     float32
 	RefRing              *audio.Int16Ring
+	Undo                 *undo.Buffer
 	DumpAudioDir         string
 	IPCServer            *ipc.Server
+	DBusService          *dbussvc.Service
+	// Reload re-reads the settings on SIGHUP or an IPC reload command and
+	// returns the config to use from the next session. Nil disables reload.
+	Reload func(ListenConfig) (ListenConfig, error)
 }
 
 type Listener struct {
@@ -166,6 +178,28 @@ type Listener struct {
 	aecMicRing       *audio.Int16Ring
 	aecRefRing       *audio.Int16Ring
 	aecDumpProcessed io.Writer
+	// codeWords gates typing on the start and stop phrases; nil when they
+	// are not configured
+	codeWords *codeword.Gate
+	// mu guards what UpdateSettings changes while the receive goroutines
+	// run: the language, prompt, model and instructions in config, and
+	// voiceCommands. Use liveConfig to read config from those goroutines.
+	mu sync.Mutex
+	// voiceCommands recognises editing commands; nil when disabled
+	voiceCommands *voicecmd.Matcher
+	// inputMu serialises use of the input controller, and of keyboard, by
+	// the receive goroutines and undo, so their key presses do not
+	// interleave and undo never runs between typing text and recording it
+	inputMu   sync.Mutex
+	postproc  postproc.Pipeline
+	corrector *correction.Corrector
+	// partial is the transcript so far of item partialItem
+	partialItem string
+	partial     string
+	output      output.Sink
+	// keyboard is the output sink typing transcripts, which also types
+	// partial transcripts; nil when text is not typed
+	keyboard *output.Keyboard
 }
 
 func NewListener(config ListenConfig, streamConfig audio.StreamConfig, rtCli *openairt.Client, statePath string, processor audio.AudioProcessor) *Listener {
@@ -182,6 +216,19 @@ func NewListener(config ListenConfig, streamConfig audio.StreamConfig, rtCli *op
 		processor:    processor,
 	}
 	l.chunkWriter = audio.NewChunkWriter(l.ctx, l.audioChunks)
+	if config.Mode != "assistant" && config.CodeWordStart != "" {
+		l.codeWords = codeword.New(config.CodeWordStart, config.CodeWordStop)
+	}
+	if config.Mode != "assistant" && config.VoiceCommands {
+		l.voiceCommands = voiceCommandMatcher(config)
+	}
+	if config.Mode != "assistant" {
+		l.output, l.keyboard = newOutput(config)
+	}
+	l.postproc = loadPostprocessor(config)
+	if config.Mode != "assistant" {
+		l.corrector = newCorrector(config)
+	}
 	l.audioPlayChunks = make(chan *bytes.Buffer, 1024)
 	playbackRate := streamConfig.OutputSampleRate
 	if playbackRate == 0 {
@@ -286,39 +333,86 @@ func (l *Listener) Start() error {
 	conn, err := l.rtCli.Connect(initCtx, opts...)
 	if err != nil {
 		log.Println("Listener.Start: realtime connect: ", err)
+		metricConnectFailures.Inc()
 		finishInit()
 		return err
 	}
 	l.conn = conn
 	log.Println("Listener.Start: Connected to realtime API, waiting for session.created event...")
 	if err := waitForSessionUpdated(initCtx, l.conn); err != nil {
+		metricConnectFailures.Inc()
 		finishInit()
 		return err
 	}
-	if l.config.Mode == "assistant" {
-		err = l.startAssistantSession(initCtx)
-	} else {
-		err = l.startTranscriptionSession(initCtx)
-	}
-	if err != nil {
+	if err = l.sendSessionUpdate(initCtx, "Initial update"); err != nil {
 		log.Println("Listener.Start: error sending initial update: ", err)
+		metricConnectFailures.Inc()
 		finishInit()
 		return err
 	}
 	if err := waitForSessionUpdated(initCtx, l.conn); err != nil {
+		metricConnectFailures.Inc()
 		finishInit()
 		return err
 	}
 	finishInit()
+	metricConnects.Inc()
 	log.Println("Listener.Start: Record/Transcribe...")
 	if err := pid.WriteState(l.statePath, true); err != nil {
 		log.Println("Listener.Start: failed to write recording state: ", err)
 	}
 	l.config.UI.Send(&gui.ShowListeningMsg{})
+	if l.codeWords != nil {
+		l.config.UI.Send(&gui.ShowDictationPausedMsg{StartPhrase: l.config.CodeWordStart})
+	}
 
 	return nil
 }
 
+// sendSessionUpdate configures the realtime session from l.config.
+func (l *Listener) sendSessionUpdate(ctx context.Context, eventID string) error {
+	var update openairt.SessionUpdateEvent
+	l.mu.Lock()
+	if l.config.Mode == "assistant" {
+		var err error
+		if update, err = l.assistantSessionUpdate(); err != nil {
+			l.mu.Unlock()
+			return err
+		}
+	} else {
+		update = transcriptionSessionUpdate(l.config)
+	}
+	l.mu.Unlock()
+	update.EventID = eventID
+	return l.conn.SendMessage(ctx, update)
+}
+
+// UpdateSettings applies the language, prompt, transcription model and
+// instructions of config to the running session. A new language also
+// selects its voice commands. The mode of a running session cannot be
+// changed.
+func (l *Listener) UpdateSettings(config ListenConfig) error {
+	l.mu.Lock()
+	langChanged := config.Lang != l.config.Lang
+	l.config.Lang = config.Lang
+	l.config.Prompt = config.Prompt
+	l.config.Model = config.Model
+	l.config.Instructions = config.Instructions
+	if langChanged && l.config.Mode != "assistant" && l.config.VoiceCommands {
+		l.voiceCommands = voiceCommandMatcher(l.config)
+	}
+	l.mu.Unlock()
+	return l.sendSessionUpdate(l.ctx, "Settings update")
+}
+
+// liveConfig returns a copy of l.config which is safe to use while
+// UpdateSettings may change it.
+func (l *Listener) liveConfig() ListenConfig {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.config
+}
+
 func (l *Listener) RunAudio() {
 	if l.config.Mode == "assistant" {
 		l.runAudioAssistant()
@@ -354,6 +448,7 @@ func (l *Listener) SendChunks() {
 			log.Println("Listener.SendChunks: error sending message: ", err)
 			continue
 		}
+		metricBytesSent.Add(float64(cur.Len()))
 	}
 }
 
@@ -381,22 +476,61 @@ func (l *Listener) Stop() {
 	}
 }
 
-func listen(config ListenConfig) {
-	if config.IPCServer != nil {
-		lw := io.MultiWriter(os.Stderr, &ipcLogWriter{server: config.IPCServer})
-		log.SetOutput(lw)
-	}
+func newRealtimeClient(config ListenConfig) *openairt.Client {
+	rtConf := openairt.DefaultConfig(config.APIKey)
+	rtConf.BaseURL = config.WSAPIBase
+	rtConf.APIBaseURL = config.HTTPAPIBase
+	rtConf.HTTPClient = &http.Client{Timeout: config.Timeout}
+	return openairt.NewClientWithConfig(rtConf)
+}
 
-	mctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
-		log.Print("internal/audio: ", message)
-	})
+// reloadListenConfig applies config.Reload, keeping the current config if
+// it fails. The session in progress, if any, is not affected.
+func reloadListenConfig(config ListenConfig, rtCli *openairt.Client) (ListenConfig, *openairt.Client, error) {
+	if config.Reload == nil {
+		log.Println("listen: reload is not supported")
+		return config, rtCli, errors.New("reload is not supported")
+	}
+	log.Println("listen: reloading settings")
+	next, err := config.Reload(config)
 	if err != nil {
-		log.Fatalln("listen: ", err)
+		log.Println("listen: failed to reload settings, keeping the current ones: ", err)
+		return config, rtCli, err
 	}
-	defer func() {
-		_ = mctx.Uninit()
-		mctx.Free()
-	}()
+	broadcastSettings(next)
+	return next, newRealtimeClient(next), nil
+}
+
+// ipcCapabilities describes what config enables to IPC clients.
+func ipcCapabilities(config ListenConfig) ipc.Capabilities {
+	caps := ipc.Capabilities{
+		Mode: config.Mode,
+		AEC:  config.EnableAEC && config.Mode == "assistant",
+	}
+	if config.Mode == "assistant" {
+		if config.EnableDotool {
+			caps.Tools = append(caps.Tools, functionNameInputControl)
+		}
+		if config.ScreenshotCommand != "" && config.ScreenshotFile != "" {
+			caps.Tools = append(caps.Tools, functionNameTakeScreenshot)
+		}
+	}
+	return caps
+}
+
+// audioPipeline is the audio setup for one mode: the capture stream and,
+// in assistant mode, the AEC engine and its monitor capture.
+type audioPipeline struct {
+	mode         string
+	streamConfig audio.StreamConfig
+	processor    audio.AudioProcessor
+	refRing      *audio.Int16Ring
+	engine       *localvqe.LocalVQE
+	stopMonitor  context.CancelFunc
+}
+
+func newAudioPipeline(mctx *malgo.AllocatedContext, config ListenConfig) (*audioPipeline, error) {
+	p := &audioPipeline{mode: config.Mode, stopMonitor: func() {}}
 
 	// In assistant mode with duplex audio, use the higher of input/output sample rates
 	// Downsampling will be handled in the audio package
@@ -409,7 +543,7 @@ func listen(config ListenConfig) {
 	}
 
 	periodMs := 20
-	streamConfig := audio.StreamConfig{
+	p.streamConfig = audio.StreamConfig{
 		Format:           malgo.FormatS16,
 		Channels:         1,
 		SampleRate:       sampleRate,
@@ -421,12 +555,12 @@ func listen(config ListenConfig) {
 
 	captureDeviceName := config.CaptureDevice
 	if captureDeviceName != "" {
-		found, err := streamConfig.SetCaptureDeviceByName(&mctx.Context, captureDeviceName)
+		found, err := p.streamConfig.SetCaptureDeviceByName(&mctx.Context, captureDeviceName)
 		if err != nil {
-			log.Fatalln("Failed to query devices:", err)
+			return nil, fmt.Errorf("failed to query devices: %w", err)
 		}
 		if !found {
-			log.Fatalf("Capture device not found: %s\nRun 'voxinput devices' to list available devices.", captureDeviceName)
+			return nil, fmt.Errorf("capture device not found: %s\nRun 'voxinput devices' to list available devices", captureDeviceName)
 		}
 		log.Printf("Using capture device: %s", captureDeviceName)
 	}
@@ -436,15 +570,14 @@ func listen(config ListenConfig) {
 	// needs to grow its scratch at runtime.
 	maxProcessBytes := 2 * periodMs * sampleRate / 1000 * 2
 
-	var processor audio.AudioProcessor
 	if config.EnableAEC && config.Mode == "assistant" {
 		modelPath, err := localvqe.EnsureModel(config.LocalVQEModelPath, config.LocalVQEModelVersion)
 		if err != nil {
-			log.Fatalf("listen: failed to ensure localvqe model: %v", err)
+			return nil, fmt.Errorf("failed to ensure localvqe model: %w", err)
 		}
 		libPath, err := localvqe.EnsureLib(config.LocalVQELibPath)
 		if err != nil {
-			log.Fatalf("listen: failed to find localvqe lib: %v", err)
+			return nil, fmt.Errorf("failed to find localvqe lib: %w", err)
 		}
 		// Cap LocalVQE inference threads on many-core hosts. GGML's CPU
 		// backend honours the count we pass without further capping, so
@@ -457,24 +590,26 @@ func listen(config ListenConfig) {
 		if os.Getenv("GGML_NTHREADS") == "" && runtime.NumCPU()-1 >= 4 {
 			os.Setenv("GGML_NTHREADS", "4")
 		}
-		engine, err := localvqe.New(libPath, modelPath)
+		p.engine, err = localvqe.New(libPath, modelPath)
 		if err != nil {
-			log.Fatalf("listen: failed to create localvqe engine: %v", err)
+			return nil, fmt.Errorf("failed to create localvqe engine: %w", err)
 		}
 		if config.AECNoiseGate {
-			if err := engine.SetNoiseGate(true, config.AECNoiseGateDBFS); err != nil {
-				log.Fatalf("listen: failed to enable noise gate: %v", err)
+			if err := p.engine.SetNoiseGate(true, config.AECNoiseGateDBFS); err != nil {
+				p.Close()
+				return nil, fmt.Errorf("failed to enable noise gate: %w", err)
 			}
 			log.Printf("listen: LocalVQE noise gate enabled (threshold=%.1f dBFS)", config.AECNoiseGateDBFS)
 		}
-		processor = audio.NewLocalVQEProcessor(engine, sampleRate, maxProcessBytes)
+		p.processor = audio.NewLocalVQEProcessor(p.engine, sampleRate, maxProcessBytes)
 		log.Printf("listen: LocalVQE AEC enabled (modelRate=%d, deviceRate=%d, hopLength=%d, refSource=%s, maxProcessBytes=%d)",
-			engine.SampleRate(), sampleRate, engine.HopLength(), config.AECRefSource, maxProcessBytes)
+			p.engine.SampleRate(), sampleRate, p.engine.HopLength(), config.AECRefSource, maxProcessBytes)
 	}
 
-	if processor != nil && config.AECRefSource == AECRefMonitor {
+	if p.processor != nil && config.AECRefSource == AECRefMonitor {
 		if config.AECMonitorDevice == "" {
-			log.Fatalln("listen: VOXINPUT_AEC_REF_SOURCE=monitor requires VOXINPUT_AEC_MONITOR_DEVICE (or --aec-monitor-device)")
+			p.Close()
+			return nil, errors.New("VOXINPUT_AEC_REF_SOURCE=monitor requires VOXINPUT_AEC_MONITOR_DEVICE (or --aec-monitor-device)")
 		}
 		monitorConfig := audio.StreamConfig{
 			Format:       malgo.FormatS16,
@@ -485,16 +620,18 @@ func listen(config ListenConfig) {
 		}
 		found, err := monitorConfig.SetCaptureDeviceByName(&mctx.Context, config.AECMonitorDevice)
 		if err != nil {
-			log.Fatalln("listen: failed to query monitor capture devices: ", err)
+			p.Close()
+			return nil, fmt.Errorf("failed to query monitor capture devices: %w", err)
 		}
 		if !found {
-			log.Fatalf("listen: monitor device not found: %s\nRun 'voxinput devices' to list available capture devices.", config.AECMonitorDevice)
+			p.Close()
+			return nil, fmt.Errorf("monitor device not found: %s\nRun 'voxinput devices' to list available capture devices", config.AECMonitorDevice)
 		}
-		config.RefRing = audio.NewInt16Ring(sampleRate) // ~1s buffer
+		p.refRing = audio.NewInt16Ring(sampleRate) // ~1s buffer
 		monitorCtx, cancelMonitor := context.WithCancel(context.Background())
-		defer cancelMonitor()
+		p.stopMonitor = cancelMonitor
 		go func() {
-			if err := audio.CaptureToRing(monitorCtx, config.RefRing, monitorConfig); err != nil &&
+			if err := audio.CaptureToRing(monitorCtx, p.refRing, monitorConfig); err != nil &&
 				!errors.Is(err, context.Canceled) {
 				log.Printf("listen: monitor capture ended: %v", err)
 			}
@@ -502,22 +639,73 @@ func listen(config ListenConfig) {
 		log.Printf("listen: AEC monitor capture started (device=%q, rate=%d)", config.AECMonitorDevice, sampleRate)
 	}
 
-	rtConf := openairt.DefaultConfig(config.APIKey)
-	rtConf.BaseURL = config.WSAPIBase
-	rtConf.APIBaseURL = config.HTTPAPIBase
-	rtConf.HTTPClient = &http.Client{Timeout: config.Timeout}
-	rtCli := openairt.NewClientWithConfig(rtConf)
+	return p, nil
+}
+
+// Close stops the monitor capture and frees the AEC engine. It must not be
+// called while a Listener is using the pipeline.
+func (p *audioPipeline) Close() {
+	p.stopMonitor()
+	if p.engine != nil {
+		p.engine.Close()
+		p.engine = nil
+	}
+}
+
+// mergeCommands returns a channel carrying the commands from all the
+// front ends, or nil if there are none.
+func mergeCommands(chans ...<-chan ipc.Command) <-chan ipc.Command {
+	switch len(chans) {
+	case 0:
+		return nil
+	case 1:
+		return chans[0]
+	}
+	merged := make(chan ipc.Command)
+	for _, ch := range chans {
+		go func() {
+			for cmd := range ch {
+				merged <- cmd
+			}
+		}()
+	}
+	return merged
+}
+
+func listen(config ListenConfig) {
+	if config.IPCServer != nil {
+		lw := io.MultiWriter(os.Stderr, &ipcLogWriter{server: config.IPCServer})
+		log.SetOutput(lw)
+		config.IPCServer.SetCapabilities(ipcCapabilities(config))
+	}
+
+	mctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
+		log.Print("internal/audio: ", message)
+	})
+	if err != nil {
+		log.Fatalln("listen: ", err)
+	}
+	defer func() {
+		_ = mctx.Uninit()
+		mctx.Free()
+	}()
+
+	pipeline, err := newAudioPipeline(mctx, config)
+	if err != nil {
+		log.Fatalln("listen: ", err)
+	}
+	defer func() { pipeline.Close() }()
+	config.RefRing = pipeline.refRing
+
+	rtCli := newRealtimeClient(config)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGUSR1)
 	signal.Notify(sigChan, syscall.SIGUSR2)
 	signal.Notify(sigChan, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGHUP)
 
-	statePath, err := pid.StatePath()
-	if err != nil {
-		log.Fatalln("listen: failed to get state file path: ", err)
-	}
-
+	statePath := config.StatePath
 	err = pid.Write(config.PIDPath)
 	defer func() {
 		if err := os.Remove(config.PIDPath); err != nil {
@@ -532,15 +720,37 @@ func listen(config ListenConfig) {
 		log.Println("listen: failed to write initial state: ", err)
 	}
 
-	var ipcCmds <-chan ipc.Command
+	var cmdChans []<-chan ipc.Command
 	if config.IPCServer != nil {
-		ipcCmds = config.IPCServer.Commands()
+		cmdChans = append(cmdChans, config.IPCServer.Commands())
+	}
+	if config.DBusService != nil {
+		cmdChans = append(cmdChans, config.DBusService.Commands())
+	}
+	ipcCmds := mergeCommands(cmdChans...)
+
+	// ctx is cancelled when listen returns, stopping work such as an undo
+	// started while no session was running
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// undos tracks the undo commands being run off the loop. A session
+	// only starts once they are done, so they do not type over it.
+	var undos sync.WaitGroup
+	runUndo := func(ctx context.Context, cmd ipc.Command, config ListenConfig, l *Listener) {
+		undos.Add(1)
+		go func() {
+			defer undos.Done()
+			handleUndo(ctx, cmd, config, l)
+		}()
 	}
 
 ForListen:
 	for {
 		log.Println("listen: Waiting for record signal...")
 
+		// startCmd and stopCmd are the IPC commands, if any, that started
+		// or stopped this session; they are answered once that has happened.
+		var startCmd, stopCmd ipc.Command
 		var sig os.Signal
 		for {
 			select {
@@ -553,6 +763,9 @@ ForListen:
 					continue
 				case syscall.SIGTERM:
 					break ForListen
+				case syscall.SIGHUP:
+					config, rtCli, _ = reloadListenConfig(config, rtCli)
+					continue
 				}
 				if sig == syscall.SIGUSR1 {
 					break
@@ -560,25 +773,63 @@ ForListen:
 				continue
 			case cmd := <-ipcCmds:
 				switch cmd.Kind {
-				case ipc.CommandRecord:
-					// start
+				case ipc.CommandRecord, ipc.CommandToggle:
+					startCmd = cmd
 				case ipc.CommandStop:
 					log.Println("listen: Received IPC stop, but wasn't recording")
+					replyState(cmd, false)
+					continue
+				case ipc.CommandStatus:
+					replyState(cmd, false)
+					continue
+				case ipc.CommandSet:
+					config = handleSet(cmd, config, nil)
+					continue
+				case ipc.CommandHistory:
+					handleHistory(cmd, config)
+					continue
+				case ipc.CommandUndo:
+					runUndo(ctx, cmd, config, nil)
 					continue
 				case ipc.CommandQuit:
 					break ForListen
+				case ipc.CommandReload:
+					if config, rtCli, err = reloadListenConfig(config, rtCli); err != nil {
+						cmd.ReplyError(err)
+					} else {
+						replyState(cmd, false)
+					}
+					continue
 				default:
+					cmd.ReplyError(fmt.Errorf("unknown command %q", cmd.Kind))
 					continue
 				}
 			}
 			break
 		}
 
-		l := NewListener(config, streamConfig, rtCli, statePath, processor)
+		// The mode may have been changed since the pipeline was built
+		if config.Mode != pipeline.mode {
+			if next, err := newAudioPipeline(mctx, config); err != nil {
+				log.Printf("listen: failed to switch to %s mode, staying in %s mode: %v", config.Mode, pipeline.mode, err)
+				config.Mode = pipeline.mode
+				broadcastSettings(config)
+			} else {
+				pipeline.Close()
+				pipeline = next
+				log.Printf("listen: switched to %s mode", config.Mode)
+			}
+			config.RefRing = pipeline.refRing
+		}
+
+		undos.Wait()
+		l := NewListener(config, pipeline.streamConfig, rtCli, statePath, pipeline.processor)
 		if err := l.Start(); err != nil {
 			l.cancel()
+			startCmd.Reply(ipc.Event{Text: "idle", Error: fmt.Sprintf("failed to start session: %v", err)})
 			continue
 		}
+		replyState(startCmd, true)
 
 		go l.RunAudio()
 		go l.SendChunks()
@@ -601,17 +852,37 @@ ForListen:
 					l.config.UI.Send(&gui.ShowStoppingMsg{})
 					l.Stop()
 					break ForListen
+				case syscall.SIGHUP:
+					config, rtCli, _ = reloadListenConfig(config, rtCli)
 				}
 			case cmd := <-ipcCmds:
 				switch cmd.Kind {
 				case ipc.CommandRecord:
 					log.Println("listen: received IPC record, but already recording")
-				case ipc.CommandStop:
+					replyState(cmd, true)
+				case ipc.CommandStop, ipc.CommandToggle:
+					stopCmd = cmd
 					break ForSignal
+				case ipc.CommandStatus:
+					replyState(cmd, true)
+				case ipc.CommandSet:
+					config = handleSet(cmd, config, l)
+				case ipc.CommandHistory:
+					handleHistory(cmd, config)
+				case ipc.CommandUndo:
+					runUndo(l.ctx, cmd, config, l)
 				case ipc.CommandQuit:
 					l.config.UI.Send(&gui.ShowStoppingMsg{})
 					l.Stop()
 					break ForListen
+				case ipc.CommandReload:
+					if config, rtCli, err = reloadListenConfig(config, rtCli); err != nil {
+						cmd.ReplyError(err)
+					} else {
+						replyState(cmd, true)
+					}
+				default:
+					cmd.ReplyError(fmt.Errorf("unknown command %q", cmd.Kind))
 				}
 			case <-l.ctx.Done():
 				break ForSignal
@@ -620,6 +891,7 @@ ForListen:
 
 		l.config.UI.Send(&gui.ShowStoppingMsg{})
 		l.Stop()
+		replyState(stopCmd, false)
 
 		for {
 			select {
@@ -633,3 +905,12 @@ ForListen:
 		}
 	}
 }
+
+// replyState answers an IPC command with whether the listener is recording.
+func replyState(cmd ipc.Command, recording bool) {
+	text, state := "idle", ipc.StateIdle
+	if recording {
+		text, state = "recording", ipc.StateListening
+	}
+	cmd.Reply(ipc.Event{Text: text, Recording: recording, State: state})
+}
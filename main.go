@@ -6,19 +6,17 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"slices"
-	"strconv"
 	"strings"
-	"syscall"
-	"time"
 
 	"github.com/richiejp/VoxInput/internal/audio"
+	"github.com/richiejp/VoxInput/internal/dbussvc"
 	"github.com/richiejp/VoxInput/internal/gui"
 	"github.com/richiejp/VoxInput/internal/input"
 	"github.com/richiejp/VoxInput/internal/ipc"
-	"github.com/richiejp/VoxInput/internal/localvqe"
+	"github.com/richiejp/VoxInput/internal/metrics"
 	"github.com/richiejp/VoxInput/internal/pid"
 	"github.com/richiejp/VoxInput/internal/semver"
+	"github.com/richiejp/VoxInput/internal/undo"
 )
 
 //go:embed version.txt
@@ -42,10 +40,27 @@ func main() {
 	case "help":
 		fmt.Println(`Available commands:
   listen - Start speech to text daemon
+           --profile <name> Use the named profile from the config file
+           --config <path> Read profiles from the given config file
            --replay play the audio just recorded for transcription
            --no-realtime use the HTTP API instead of the realtime API; disables VAD
            --no-show-status don't show when recording has started or stopped
+           --output <sinks> Where transcripts go, comma separated: keyboard, clipboard, file, stdout, command, http
+                            (default: file with --output-file, otherwise keyboard)
            --output-file <path> Write transcribed text to file instead of keyboard
+           --output-command <command> Shell command run with each transcript on its stdin
+           --output-url <url> URL each transcript is posted to as JSON
+           --code-word-start <phrase> (transcription mode only) Only type text spoken after this phrase...
+           --code-word-stop <phrase> ...and until this one; both must be set
+           --voice-commands (transcription mode only) Run spoken commands such as "new line" or "delete that" instead of typing them
+           --voice-commands-file <path> JSON file with extra voice commands per language
+           --type-partial (transcription mode only) Type the transcript while speaking and correct it when the utterance ends
+           --no-history Don't record transcripts in the history file
+           --history-file <path> Where to record the history (default: $XDG_STATE_HOME/voxinput/history.jsonl)
+           --postprocess-file <path> JSON file describing how to clean up transcripts before they are output
+           --correction Send each transcript to a chat model to fix misheard words before it is output
+           --correction-model <model> Chat model used for correction (default: gpt-4o-mini)
+           --correction-glossary <terms> Comma separated terms the correction model should expect
            --prompt <text> Text used to condition model output. Could be previously transcribed text or uncommon words you expect to use
            --mode <transcription|assistant> (realtime only, default: transcription)
            --instructions <text> System prompt for the assistant model
@@ -55,6 +70,11 @@ func main() {
            --screenshot-file <path> (assistant mode only) Path where the screenshot command saves its output
            --dump-audio <dir> (assistant mode only) Dump raw mic and speaker PCM to files for AEC analysis
            --socket <path> Enable IPC socket server at the given path for TUI connections
+           --dbus Expose Record/Stop/Toggle/Status methods and state signals on the D-Bus session bus
+           --http-addr <host:port> Serve the IPC commands and events over HTTP on a loopback address
+           --metrics-addr <host:port> Serve Prometheus metrics at http://<host:port>/metrics
+           --instance <name> Run as a named instance with its own PID, state and socket files, so several
+                             listeners can run side by side (the socket is enabled by default)
 
   tui    - Launch interactive terminal UI with chat and log tabs
            --connect <path> Connect to an existing listen process socket instead of starting a subprocess
@@ -65,11 +85,62 @@ func main() {
   stop   - Alias for write; makes more sense in realtime mode
   toggle - Toggle recording on/off (start recording if idle, stop if recording)
   status - Show whether the server is listening and if it's currently recording
+           --follow Print a line on every state change (needs the IPC socket)
+           --format <plain|waybar|i3bar> Output format for status bars
+  reload - Tell existing listener to re-read its settings (same as sending SIGHUP). Changes apply
+           from the next recording; settings such as sample rates, mode and AEC need a restart
+  undo   - Erase the text typed for the last utterance with Backspace, over the listener's IPC socket.
+           Refused when the text is too old or long, or the focused window changed
+  set    - Change settings of the running listener over its IPC socket. The language, prompt, model and
+           instructions also update a recording in progress; the mode applies from the next recording
+           --lang <code> Transcription language
+           --prompt <text> Text used to condition the transcription
+           --model <name> Transcription model
+           --mode <transcription|assistant> Realtime mode
+           --instructions <text> System prompt for the assistant model
+  instances - List running listener instances with their PID, status and mode
   devices - List capture devices
+  events - Print events from the listener's IPC socket as JSON lines until it exits
+           --kind <kind> Only print events of this kind (` + eventKindList() + `);
+                         repeatable or comma separated
+           --once Exit after the first printed event
+           --until <kind> Exit after the first event of this kind, e.g. --until transcript
+           --replay Start with the recent events buffered by the listener
+           --socket <path> Socket to connect to (default: VOXINPUT_SOCKET or $XDG_RUNTIME_DIR/VoxInput.sock)
+  history - Print the transcripts and assistant replies recorded by listen
+           --since <time> Only entries from this long ago (e.g. 2h), a date (2006-01-02) or an RFC 3339 time
+           --grep <regexp> Only entries matching this case-insensitive regular expression
+           --limit <n> Only the last n entries
+           --json Print the entries as JSON lines
+  transcribe <file> - Print the timestamped transcript of a WAV file ("-" reads stdin). The audio is resampled to
+           the input sample rate and streamed through the realtime API with server VAD, or sent in one request
+           to the HTTP API with --no-realtime (raise VOXINPUT_TRANSCRIPTION_TIMEOUT for long files)
+           --raw The file is raw 16-bit little-endian PCM instead of WAV
+           --rate <hz> Sample rate of the raw PCM (required with --raw)
+           --channels <n> Interleaved channels of the raw PCM (default: 1)
+           --format <text|srt|vtt|json> Output format (default: text)
+           Accepts the same flags as listen, e.g. --lang <code> or --profile <name>
+  config show - Print the effective listen settings and where each value came from
+           --json Print the settings as JSON
+           Accepts the same flags as listen, e.g. --profile <name>
+  doctor - Check that everything listen depends on is installed, configured and reachable
+           Accepts the same flags as listen
   help   - Show this help message
   ver    - Print version
 
+record, write, stop, toggle, status and reload use the listener's IPC socket when
+it has one (--socket, VOXINPUT_SOCKET or the default path) and signals otherwise.
+All commands that talk to a listener accept --instance <name> to select a named
+instance.
+
+Settings are taken from flags, then environment variables, then the selected
+profile of the config file ($XDG_CONFIG_HOME/voxinput/config.json), then defaults.
+Profile keys are the VOXINPUT_ variable names in lower case without the prefix,
+e.g. "transcription_model" for VOXINPUT_TRANSCRIPTION_MODEL.
+
 Environment variables:
+  VOXINPUT_CONFIG - Path to the config file (default: $XDG_CONFIG_HOME/voxinput/config.json)
+  VOXINPUT_PROFILE - Name of the config file profile to use (default: the file's "profile" field)
   VOXINPUT_API_KEY or OPENAI_API_KEY - OpenAI API key (default: sk-xxx)
   VOXINPUT_BASE_URL or OPENAI_BASE_URL - HTTP API base URL (default: http://localhost:8080/v1)
   VOXINPUT_WS_BASE_URL or OPENAI_WS_BASE_URL - WebSocket API base URL (default: ws://localhost:8080/v1/realtime)
@@ -85,7 +156,25 @@ Environment variables:
   VOXINPUT_TRANSCRIPTION_TIMEOUT or TRANSCRIPTION_TIMEOUT - Transcription timeout (default: 30s)
   VOXINPUT_SHOW_STATUS or SHOW_STATUS - Show status notifications (yes/no, default: yes)
   VOXINPUT_CAPTURE_DEVICE - Name of the capture device (default: system default; use 'devices' to list)
+  VOXINPUT_OUTPUT - Comma separated output sinks: keyboard, clipboard, file, stdout, command, http
+                    (default: file if VOXINPUT_OUTPUT_FILE is set, otherwise keyboard)
   VOXINPUT_OUTPUT_FILE - File to write transcribed text to (instead of keyboard)
+  VOXINPUT_OUTPUT_COMMAND - Shell command run with each transcript on its stdin, for the command output
+  VOXINPUT_OUTPUT_URL - URL each transcript is posted to, for the http output
+  VOXINPUT_CODE_WORD_START and VOXINPUT_CODE_WORD_STOP - Spoken phrases which start and stop typing in realtime transcription mode, e.g. "start dictation" and "stop dictation" (default: none, always type)
+  VOXINPUT_VOICE_COMMANDS - Run spoken editing commands in realtime transcription mode (yes/no, default: no)
+  VOXINPUT_VOICE_COMMANDS_FILE - JSON file with extra voice commands per language (default: none)
+  VOXINPUT_UNDO_TIMEOUT - How long after typing undo may still erase the text (default: 60s)
+  VOXINPUT_UNDO_MAX_CHARS - The most characters undo erases at once (default: 500)
+  VOXINPUT_UNDO_FOCUS_COMMAND - Shell command printing an ID of the focused window, checked by undo (default: none)
+  VOXINPUT_TYPE_PARTIAL - Type partial transcripts while speaking in realtime transcription mode (yes/no, default: no)
+  VOXINPUT_HISTORY - Record transcripts and assistant replies in the history file (yes/no, default: yes)
+  VOXINPUT_HISTORY_FILE - History file (default: $XDG_STATE_HOME/voxinput/history.jsonl)
+  VOXINPUT_POSTPROCESS_FILE - JSON file with filler words, a dictionary and rewrites applied to transcripts (default: none)
+  VOXINPUT_CORRECTION - Correct transcripts with a chat model before they are output (yes/no, default: no)
+  VOXINPUT_CORRECTION_MODEL - Chat completions model used for correction (default: gpt-4o-mini)
+  VOXINPUT_CORRECTION_GLOSSARY - Comma separated terms, such as product names, the correction model should expect (default: none)
+  VOXINPUT_CORRECTION_TIMEOUT - How long to wait for a correction before typing the raw transcript (default: 5s)
   VOXINPUT_PROMPT - Text used to condition the transcription model output. Could be previously transcribed text or uncommon words you expect to use (default: none)
   VOXINPUT_MODE - Realtime mode (transcription|assistant, default: transcription)
   VOXINPUT_ENABLE_AEC - Enable acoustic echo cancellation in assistant mode (yes/no, default: yes)
@@ -100,6 +189,13 @@ Environment variables:
   VOXINPUT_INPUT_SAMPLE_RATE - Sample rate for audio input/recording in Hz (default: 24000)
   VOXINPUT_OUTPUT_SAMPLE_RATE - Sample rate for audio output/playback in Hz (default: 24000)
   VOXINPUT_SOCKET - Socket path for IPC (default: $XDG_RUNTIME_DIR/VoxInput.sock)
+  VOXINPUT_DBUS - Expose the org.voxinput.Daemon service on the D-Bus session bus (yes/no, default: no)
+  VOXINPUT_HTTP_ADDR - Loopback address for the HTTP API, e.g. 127.0.0.1:7878 (default: none, disabled)
+  VOXINPUT_HTTP_TOKEN - Bearer token for the HTTP API (default: generated and written to
+                        $XDG_RUNTIME_DIR/VoxInput.token, or VoxInput-<instance>.token)
+  VOXINPUT_METRICS_ADDR - Loopback address to serve Prometheus metrics on, e.g. 127.0.0.1:9464 (default: none, disabled)
+  VOXINPUT_INSTANCE - Name of the listener instance to run or control (default: none); a named instance
+                      uses VoxInput-<name>.pid, .state and .sock instead of VoxInput.pid, .state and .sock
   XDG_RUNTIME_DIR - Directory for PID and state files (required, standard XDG variable)`)
 		return
 	case "ver":
@@ -108,298 +204,136 @@ Environment variables:
 	case "tui":
 		tuiCommand(os.Args[2:])
 		return
+	case "config":
+		configCommand(os.Args[2:])
+		return
+	case "doctor":
+		doctorCommand(os.Args[2:])
+		return
+	case "events":
+		eventsCommand(os.Args[2:])
+		return
+	case "history":
+		historyCommand(os.Args[2:])
+		return
+	case "transcribe":
+		transcribeCommand(os.Args[2:])
+		return
+	case "set":
+		setCommand(os.Args[2:])
+		return
+	case "instances":
+		instancesCommand()
+		return
 	default:
 	}
 
-	pidPath, err := pid.Path()
+	instance, err := resolveInstance(os.Args[2:])
+	if err != nil {
+		log.Fatalln("main: ", err)
+	}
+	pidPath, err := pid.Path(instance)
 	if err != nil {
 		log.Fatalln("main: failed to get PID file path: ", err)
 	}
-
-	statePath, err := pid.StatePath()
+	statePath, err := pid.StatePath(instance)
 	if err != nil {
 		log.Fatalln("main: failed to get state file path: ", err)
 	}
 
 	if cmd == "listen" {
-		apiKey := getOpenaiEnv("API_KEY", "sk-xxx")
-		httpApiBase := getOpenaiEnv("BASE_URL", "http://localhost:8080/v1")
-		wsApiBase := getOpenaiEnv("WS_BASE_URL", "ws://localhost:8080/v1/realtime")
-
-		// VOXINPUT_LANG takes precedence and is used as-is to allow setting language names that diverge from OpenAI's for e.g. Qwen ASR uses names like "English"
-		// LANG is truncated to first 2 characters which matches OpenAI's use of language codes (I hope)
-		lang := os.Getenv("VOXINPUT_LANG")
-		if lang == "" {
-			if langEnv := os.Getenv("LANG"); langEnv != "" && len(langEnv) >= 2 {
-				lang = langEnv[:2]
-			}
-		}
-		model := getPrefixedEnv([]string{"VOXINPUT", ""}, "TRANSCRIPTION_MODEL", "")
-		assistantModel := getPrefixedEnv([]string{"VOXINPUT", ""}, "ASSISTANT_MODEL", "gpt-realtime")
-		assistantVoice := getPrefixedEnv([]string{"VOXINPUT", ""}, "ASSISTANT_VOICE", "")
-		instructions := getPrefixedEnv([]string{"VOXINPUT", ""}, "ASSISTANT_INSTRUCTIONS", "")
-		enableDotoolStr := getPrefixedEnv([]string{"VOXINPUT"}, "ASSISTANT_ENABLE_DOTOOL", "yes")
-		enableAECStr := getPrefixedEnv([]string{"VOXINPUT"}, "ENABLE_AEC", "yes")
-		timeoutStr := getPrefixedEnv([]string{"VOXINPUT", ""}, "TRANSCRIPTION_TIMEOUT", "30s")
-		showStatusText := getPrefixedEnv([]string{"VOXINPUT", ""}, "SHOW_STATUS", "yes")
-		captureDeviceName := getPrefixedEnv([]string{"VOXINPUT"}, "CAPTURE_DEVICE", "")
-		prompt := getPrefixedEnv([]string{"VOXINPUT"}, "PROMPT", "")
-		outputFile := getPrefixedEnv([]string{"VOXINPUT"}, "OUTPUT_FILE", "")
-		inputSampleRateStr := getPrefixedEnv([]string{"VOXINPUT"}, "INPUT_SAMPLE_RATE", "24000")
-		outputSampleRateStr := getPrefixedEnv([]string{"VOXINPUT"}, "OUTPUT_SAMPLE_RATE", "24000")
-		dumpAudioDir := getPrefixedEnv([]string{"VOXINPUT"}, "DUMP_AUDIO_DIR", "")
-		localvqeModelPath := getPrefixedEnv([]string{"VOXINPUT"}, "LOCALVQE_MODEL", "")
-		localvqeModelVersion := getPrefixedEnv([]string{"VOXINPUT"}, "LOCALVQE_MODEL_VERSION", "")
-		localvqeLibPath := getPrefixedEnv([]string{"VOXINPUT"}, "LOCALVQE_LIB", "")
-		aecRefSourceStr := getPrefixedEnv([]string{"VOXINPUT"}, "AEC_REF_SOURCE", "playback")
-		aecMonitorDevice := getPrefixedEnv([]string{"VOXINPUT"}, "AEC_MONITOR_DEVICE", "")
-		aecNoiseGateStr := getPrefixedEnv([]string{"VOXINPUT"}, "AEC_NOISE_GATE", "no")
-		aecNoiseGateDBFSStr := getPrefixedEnv([]string{"VOXINPUT"}, "AEC_NOISE_GATE_DBFS", "-45.0")
-
-		mode := getPrefixedEnv([]string{"VOXINPUT"}, "MODE", "transcription")
-		socketPath := getPrefixedEnv([]string{"VOXINPUT"}, "SOCKET", "")
-		screenshotCommand := getPrefixedEnv([]string{"VOXINPUT"}, "ASSISTANT_SCREENSHOT_COMMAND", "")
-		screenshotFile := getPrefixedEnv([]string{"VOXINPUT"}, "ASSISTANT_SCREENSHOT_FILE", "")
-
-		timeout, err := time.ParseDuration(timeoutStr)
-		if err != nil {
-			log.Println("main: failed to parse timeout", err)
-			timeout = time.Second * 30
-		}
-
-		inputSampleRate, err := strconv.Atoi(inputSampleRateStr)
-		if err != nil {
-			log.Println("main: failed to parse input sample rate", err)
-			inputSampleRate = 24000
-		}
-
-		outputSampleRate, err := strconv.Atoi(outputSampleRateStr)
+		opts, r, err := resolveListenOptions(os.Args[2:])
 		if err != nil {
-			log.Println("main: failed to parse output sample rate", err)
-			outputSampleRate = 24000
+			log.Fatalln("main: ", err)
 		}
-
-		if lang != "" {
-			log.Println("main: language is set to ", lang)
+		if r.ProfileName() != "" {
+			log.Printf("main: using profile %q from %s", r.ProfileName(), r.Path())
 		}
-
-		if slices.Contains(os.Args[2:], "--no-show-status") {
-			showStatusText = "no"
+		if opts.Instance != "" {
+			log.Printf("main: running as instance %q", opts.Instance)
 		}
-		showStatus := !(showStatusText == "no" || showStatusText == "false")
 
-		if slices.Contains(os.Args[2:], "--no-dotool") {
-			enableDotoolStr = "no"
-		}
-		enableDotool := !(enableDotoolStr == "no" || enableDotoolStr == "false")
-
-		if slices.Contains(os.Args[2:], "--no-aec") {
-			enableAECStr = "no"
-		}
-		enableAEC := !(enableAECStr == "no" || enableAECStr == "false")
-
-		replay := slices.Contains(os.Args[2:], "--replay")
-		realtime := !slices.Contains(os.Args[2:], "--no-realtime")
-
-		var outputFileArg string
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--output-file" && i+1 < len(os.Args) {
-				outputFileArg = os.Args[i+1]
-				break
-			}
-		}
-		if outputFileArg != "" {
-			outputFile = outputFileArg
-		}
-
-		var promptArg string
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--prompt" && i+1 < len(os.Args) {
-				promptArg = os.Args[i+1]
-				break
-			}
-		}
-		if promptArg != "" {
-			prompt = promptArg
-		}
-
-		var modeArg string
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--mode" && i+1 < len(os.Args) {
-				modeArg = os.Args[i+1]
-				break
-			}
-		}
-		if modeArg != "" {
-			mode = modeArg
-		}
-
-		var instructionsArg string
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--instructions" && i+1 < len(os.Args) {
-				instructionsArg = os.Args[i+1]
-				break
-			}
-		}
-		if instructionsArg != "" {
-			instructions = instructionsArg
-		}
-
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--screenshot-command" && i+1 < len(os.Args) {
-				screenshotCommand = os.Args[i+1]
-				break
-			}
-		}
-
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--screenshot-file" && i+1 < len(os.Args) {
-				screenshotFile = os.Args[i+1]
-				break
-			}
-		}
-
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--dump-audio" && i+1 < len(os.Args) {
-				dumpAudioDir = os.Args[i+1]
-				break
-			}
-		}
-
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--socket" && i+1 < len(os.Args) {
-				socketPath = os.Args[i+1]
-				break
-			}
-		}
-
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--aec-ref-source" && i+1 < len(os.Args) {
-				aecRefSourceStr = os.Args[i+1]
-				break
-			}
-		}
-
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--aec-monitor-device" && i+1 < len(os.Args) {
-				aecMonitorDevice = os.Args[i+1]
-				break
-			}
-		}
-
-		if slices.Contains(os.Args[2:], "--aec-noise-gate") {
-			aecNoiseGateStr = "yes"
-		}
-		if slices.Contains(os.Args[2:], "--no-aec-noise-gate") {
-			aecNoiseGateStr = "no"
-		}
-		aecNoiseGate := !(aecNoiseGateStr == "no" || aecNoiseGateStr == "false")
-
-		for i := 2; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			if arg == "--aec-noise-gate-dbfs" && i+1 < len(os.Args) {
-				aecNoiseGateDBFSStr = os.Args[i+1]
-				break
-			}
-		}
-		aecNoiseGateDBFS64, err := strconv.ParseFloat(aecNoiseGateDBFSStr, 32)
-		if err != nil {
-			log.Printf("main: failed to parse VOXINPUT_AEC_NOISE_GATE_DBFS=%q, using -45.0: %v", aecNoiseGateDBFSStr, err)
-			aecNoiseGateDBFS64 = -45.0
-		}
-		aecNoiseGateDBFS := float32(aecNoiseGateDBFS64)
-
-		var aecRefSource AECRefSource
-		switch aecRefSourceStr {
-		case string(AECRefPlayback):
-			aecRefSource = AECRefPlayback
-		case string(AECRefMonitor):
-			aecRefSource = AECRefMonitor
-		default:
-			log.Fatalf("main: invalid --aec-ref-source %q (expected %q or %q)",
-				aecRefSourceStr, AECRefPlayback, AECRefMonitor)
-		}
+		config := opts.Config
+		config.PIDPath = pidPath
+		config.StatePath = statePath
+		config.Reload = newReloader(os.Args[2:], opts).Reload
+		config.Undo = undo.New()
 
 		// Create input controller for keyboard/mouse simulation.
-		// Only required when we need to type text (no output file) or use input control in assistant mode.
-		var inputCtrl input.Controller
-		needsInput := outputFile == "" || enableDotool
+		// Only required when we need to type or paste text or use input control in assistant mode.
+		needsInput := typesText(config) || config.EnableDotool
 		if needsInput {
 			var err error
-			inputCtrl, err = input.New()
+			config.InputController, err = input.New()
 			if err != nil {
 				log.Fatalln("main: failed to create input controller: ", err)
 			}
 		}
 
-		if realtime {
+		if opts.MetricsAddr != "" {
+			metricsServer, err := metrics.NewServer(opts.MetricsAddr, metrics.Default)
+			if err != nil {
+				log.Fatalln("main: failed to create metrics server:", err)
+			}
+			defer metricsServer.Close()
+			log.Printf("main: metrics available at http://%s/metrics", metricsServer.Addr())
+		}
+
+		if opts.Realtime {
 			ctx, cancel := context.WithCancel(context.Background())
-			guiSink := gui.New(ctx, showStatus)
+			guiSink := gui.New(ctx, opts.ShowStatus)
 
-			var sink gui.StatusSink = guiSink
-			var ipcServer *ipc.Server
+			sinks := []gui.StatusSink{guiSink}
 
-			if socketPath != "" {
+			// The HTTP API serves the IPC server's commands and events, so
+			// it needs one even without a socket
+			if opts.SocketPath != "" || opts.HTTPAddr != "" {
 				var err error
-				ipcServer, err = ipc.NewServer(socketPath)
+				config.IPCServer, err = ipc.NewServer(opts.SocketPath)
 				if err != nil {
 					log.Fatalln("main: failed to create IPC server:", err)
 				}
-				defer ipcServer.Close()
-				sink = &gui.MultiSink{Sinks: []gui.StatusSink{guiSink, ipcServer}}
-				log.Println("main: IPC socket server listening on", socketPath)
+				defer config.IPCServer.Close()
+				sinks = append(sinks, config.IPCServer)
+				if opts.SocketPath != "" {
+					log.Println("main: IPC socket server listening on", opts.SocketPath)
+				}
+			}
+			if opts.HTTPAddr != "" {
+				httpServer, tokenPath, err := startHTTPAPI(config.IPCServer, opts)
+				if err != nil {
+					log.Fatalln("main: failed to create HTTP API server:", err)
+				}
+				defer httpServer.Close()
+				if tokenPath != "" {
+					defer os.Remove(tokenPath)
+				}
+			}
+			if opts.DBus {
+				var err error
+				config.DBusService, err = dbussvc.ConnectSession(opts.Instance)
+				if err != nil {
+					log.Fatalln("main: failed to create D-Bus service:", err)
+				}
+				defer config.DBusService.Close()
+				sinks = append(sinks, config.DBusService)
+				log.Println("main: D-Bus service registered as", config.DBusService.Name())
+			}
+
+			if len(sinks) > 1 {
+				config.UI = &gui.MultiSink{Sinks: sinks}
+			} else {
+				config.UI = guiSink
 			}
 
 			go func() {
-				listen(ListenConfig{
-					PIDPath:              pidPath,
-					APIKey:               apiKey,
-					HTTPAPIBase:          httpApiBase,
-					WSAPIBase:            wsApiBase,
-					Lang:                 lang,
-					Model:                model,
-					Timeout:              timeout,
-					UI:                   sink,
-					CaptureDevice:        captureDeviceName,
-					OutputFile:           outputFile,
-					Prompt:               prompt,
-					Mode:                 mode,
-					AssistantModel:       assistantModel,
-					AssistantVoice:       assistantVoice,
-					Instructions:         instructions,
-					EnableDotool:         enableDotool,
-					InputController:      inputCtrl,
-					ScreenshotCommand:    screenshotCommand,
-					ScreenshotFile:       screenshotFile,
-					InputSampleRate:      inputSampleRate,
-					OutputSampleRate:     outputSampleRate,
-					EnableAEC:            enableAEC,
-					LocalVQEModelPath:    localvqeModelPath,
-					LocalVQEModelVersion: localvqe.ModelVariant(localvqeModelVersion),
-					LocalVQELibPath:      localvqeLibPath,
-					AECRefSource:         aecRefSource,
-					AECMonitorDevice:     aecMonitorDevice,
-					AECNoiseGate:         aecNoiseGate,
-					AECNoiseGateDBFS:     aecNoiseGateDBFS,
-					DumpAudioDir:         dumpAudioDir,
-					IPCServer:            ipcServer,
-				})
+				listen(config)
 				cancel()
 			}()
 
 			guiSink.Run()
 		} else {
-			listenOld(pidPath, apiKey, httpApiBase, lang, model, prompt, replay, timeout, inputCtrl)
+			listenOld(config, opts.Replay)
 		}
 
 		return
@@ -412,60 +346,5 @@ Environment variables:
 		return
 	}
 
-	id, err := pid.Read(pidPath)
-	if err != nil {
-		log.Fatalln("main: failed to read listener PID: ", err)
-	}
-
-	proc, err := os.FindProcess(id)
-	if err != nil {
-		log.Fatalln("main: Failed to find listen process: ", err)
-	}
-
-	switch cmd {
-	case "record":
-		log.Println("main: Sending record signal")
-		err = proc.Signal(syscall.SIGUSR1)
-	case "stop":
-		fallthrough
-	case "write":
-		log.Println("main: Sending stop/write signal")
-		err = proc.Signal(syscall.SIGUSR2)
-	case "status":
-		err = proc.Signal(syscall.Signal(0))
-		if err != nil {
-			log.Fatalln("main: Failed to signal listen process: ", err)
-		}
-
-		recording, err := pid.ReadState(statePath)
-		if err != nil {
-			log.Fatalln("main: Failed to read state file: ", err)
-		}
-
-		if recording {
-			fmt.Println("recording")
-		} else {
-			fmt.Println("idle")
-		}
-	case "toggle":
-		// Read current state
-		recording, readErr := pid.ReadState(statePath)
-		if readErr != nil {
-			log.Fatalln("main: Failed to read state: ", readErr)
-		}
-
-		if recording {
-			log.Println("main: Currently recording, sending stop signal")
-			err = proc.Signal(syscall.SIGUSR2)
-		} else {
-			log.Println("main: Currently idle, sending record signal")
-			err = proc.Signal(syscall.SIGUSR1)
-		}
-	default:
-		log.Fatalln("main: Unknown command: ", os.Args[1])
-	}
-
-	if err != nil {
-		log.Fatalln("main: Error sending signal: ", err)
-	}
+	clientCommand(cmd, os.Args[2:], pidPath, statePath)
 }
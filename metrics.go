@@ -0,0 +1,33 @@
+package main
+
+import "github.com/richiejp/VoxInput/internal/metrics"
+
+var (
+	metricConnects = metrics.NewCounter("voxinput_realtime_connects_total",
+		"Realtime API sessions set up; one per recording session.")
+	metricConnectFailures = metrics.NewCounter("voxinput_realtime_connect_failures_total",
+		"Failed attempts to connect to the realtime API or set up its session.")
+	metricReadRetries = metrics.NewCounter("voxinput_realtime_read_retries_total",
+		"Errors reading from the realtime API which were retried.")
+	metricBytesSent = metrics.NewCounter("voxinput_realtime_audio_bytes_sent_total",
+		"Bytes of PCM audio sent to the realtime API.")
+	metricDroppedChunks = metrics.NewCounter("voxinput_assistant_dropped_audio_chunks_total",
+		"Assistant audio chunks dropped because the playback queue was full.")
+	metricBargeInBytes = metrics.NewCounter("voxinput_assistant_barge_in_dropped_bytes_total",
+		"Bytes of queued assistant audio dropped when the user interrupted.")
+	metricTranscripts = metrics.NewCounter("voxinput_transcripts_total",
+		"Transcripts received.")
+
+	metricTranscriptionLatency = metrics.NewHistogram("voxinput_transcription_latency_seconds",
+		"Time from the end of speech to receiving its transcript.", metrics.LatencyBuckets)
+	metricOutputDuration = metrics.NewHistogram("voxinput_output_duration_seconds",
+		"Time taken to type a transcript or write it to the output file.", metrics.LatencyBuckets)
+	metricEndToEndLatency = metrics.NewHistogram("voxinput_end_to_end_latency_seconds",
+		"Time from the end of speech until its transcript was typed or written.", metrics.LatencyBuckets)
+	metricToolCallDuration = metrics.NewHistogram("voxinput_tool_call_duration_seconds",
+		"Time taken to run an assistant tool call which succeeded.", metrics.LatencyBuckets)
+	metricCorrectionDuration = metrics.NewHistogram("voxinput_correction_duration_seconds",
+		"Time taken to correct a transcript with the chat model, including failures.", metrics.LatencyBuckets)
+	metricCorrectionFallbacks = metrics.NewCounter("voxinput_correction_fallbacks_total",
+		"Transcripts output uncorrected because the chat model failed or timed out.")
+)
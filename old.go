@@ -17,11 +17,11 @@ import (
 	"github.com/sashabaranov/go-openai"
 
 	"github.com/richiejp/VoxInput/internal/audio"
-	"github.com/richiejp/VoxInput/internal/input"
+	"github.com/richiejp/VoxInput/internal/history"
 	"github.com/richiejp/VoxInput/internal/pid"
 )
 
-func listenOld(pidPath, apiKey, httpApiBase, lang, model, prompt string, replay bool, timeout time.Duration, inputCtrl input.Controller) {
+func listenOld(config ListenConfig, replay bool) {
 	mctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
 		log.Print("internal/audio: ", message)
 	})
@@ -40,25 +40,16 @@ func listenOld(pidPath, apiKey, httpApiBase, lang, model, prompt string, replay
 		MalgoContext: mctx.Context,
 	}
 
-	clientConfig := openai.DefaultConfig(apiKey)
-	clientConfig.BaseURL = httpApiBase
-	clientConfig.HTTPClient = &http.Client{
-		Timeout: timeout,
-	}
-
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGUSR1)
 	signal.Notify(sigChan, syscall.SIGUSR2)
 	signal.Notify(sigChan, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGHUP)
 
-	statePath, err := pid.StatePath()
-	if err != nil {
-		log.Fatalln("main: failed to get state file path: ", err)
-	}
-
-	err = pid.Write(pidPath)
+	statePath := config.StatePath
+	err = pid.Write(config.PIDPath)
 	defer func() {
-		if err := os.Remove(pidPath); err != nil {
+		if err := os.Remove(config.PIDPath); err != nil {
 			log.Println("main: failed to remove PID file: ", err)
 		}
 		if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
@@ -71,6 +62,10 @@ func listenOld(pidPath, apiKey, httpApiBase, lang, model, prompt string, replay
 		log.Println("main: failed to write initial state: ", err)
 	}
 
+	// The corrector lives across recordings so it has the previous
+	// transcripts as context
+	corrector := newCorrector(config)
+
 Listen:
 	for {
 		log.Println("main: Waiting for record signal...")
@@ -82,11 +77,16 @@ Listen:
 				continue
 			case syscall.SIGTERM:
 				break Listen
+			case syscall.SIGHUP:
+				config = reloadOldConfig(config)
+				corrector = newCorrector(config)
+				continue
 			}
 			break
 		}
 
 		log.Println("main: Recording...")
+		postprocessor := loadPostprocessor(config)
 
 		// Set state to recording
 		if err := pid.WriteState(statePath, true); err != nil {
@@ -113,6 +113,9 @@ Listen:
 			case syscall.SIGTERM:
 				cancel()
 				break Listen
+			case syscall.SIGHUP:
+				config = reloadOldConfig(config)
+				continue
 			}
 			break
 		}
@@ -130,6 +133,7 @@ Listen:
 		}
 
 		reader := bytes.NewReader(buf.Bytes())
+		recorded := time.Duration(buf.Len()/2) * time.Second / time.Duration(streamConfig.SampleRate)
 
 		if replay {
 			log.Println("main: Playing...")
@@ -141,6 +145,10 @@ Listen:
 			log.Println("main: Playback Done")
 		}
 
+		// Latency is measured from here so replaying the audio is not
+		// counted
+		stopped := time.Now()
+
 		wavHeader := audio.NewWAVHeader(uint32(buf.Len()), uint32(streamConfig.SampleRate))
 		var headerBuf bytes.Buffer
 		if err := wavHeader.Write(&headerBuf); err != nil {
@@ -151,13 +159,19 @@ Listen:
 		reader.Seek(0, io.SeekStart)
 		wavReader := io.MultiReader(&headerBuf, reader)
 
+		clientConfig := openai.DefaultConfig(config.APIKey)
+		clientConfig.BaseURL = config.HTTPAPIBase
+		clientConfig.HTTPClient = &http.Client{
+			Timeout: config.Timeout,
+		}
+
 		client := openai.NewClientWithConfig(clientConfig)
 		req := openai.AudioRequest{
-			Model:    model,
+			Model:    config.Model,
 			FilePath: "audio.wav",
 			Reader:   wavReader,
-			Language: lang,
-			Prompt:   prompt,
+			Language: config.Lang,
+			Prompt:   config.Prompt,
 		}
 
 		resp, err := client.CreateTranscription(context.Background(), req)
@@ -165,15 +179,43 @@ Listen:
 			log.Println("main: ", fmt.Errorf("CreateTranscription: %w", err))
 			continue Listen
 		}
+		metricTranscripts.Inc()
+		metricTranscriptionLatency.ObserveSince(stopped)
 
 		log.Println("main: transcribed text: ", resp.Text)
-
-		if inputCtrl == nil {
-			log.Println("main: no input controller available, cannot type text")
+		text := postprocessor.Process(correctTranscript(context.Background(), corrector, resp.Text))
+		if text == "" {
+			log.Println("main: nothing left after post-processing")
 			continue Listen
 		}
-		if err := inputCtrl.TypeText(context.Background(), resp.Text); err != nil {
-			log.Println("main: type text: ", err)
+		entry := history.Entry{Kind: history.KindTranscript, Text: text, DurationMs: recorded.Milliseconds()}
+		if text != resp.Text {
+			entry.Raw = resp.Text
+		}
+		recordHistory(config, entry)
+
+		outputStart := time.Now()
+		out, _ := newOutput(config)
+		if err := out.Output(context.Background(), text); err != nil {
+			log.Println("main: output: ", err)
+			continue Listen
 		}
+		metricOutputDuration.ObserveSince(outputStart)
+		metricEndToEndLatency.ObserveSince(stopped)
+	}
+}
+
+// reloadOldConfig is the listenOld counterpart of reloadListenConfig.
+func reloadOldConfig(config ListenConfig) ListenConfig {
+	if config.Reload == nil {
+		log.Println("main: reload is not supported")
+		return config
+	}
+	log.Println("main: reloading settings")
+	next, err := config.Reload(config)
+	if err != nil {
+		log.Println("main: failed to reload settings, keeping the current ones: ", err)
+		return config
 	}
+	return next
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/richiejp/VoxInput/internal/config"
+	"github.com/richiejp/VoxInput/internal/output"
+)
+
+// resolveOutput sets c.Output to the sinks listed in the output setting,
+// dropping unknown ones and those missing their setting. When none are
+// listed, transcripts go to the output file if one is set and are typed
+// otherwise, as before there was a choice.
+func resolveOutput(r *config.Resolver, c *ListenConfig) {
+	setting := r.String(config.Setting{Key: "output", Env: []string{"VOXINPUT_OUTPUT"}, Flag: "--output"})
+
+	var names, dropped []string
+	for _, name := range strings.Split(setting, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "" || slices.Contains(names, name):
+			continue
+		case !slices.Contains(output.Names, name):
+			dropped = append(dropped, fmt.Sprintf("%s is unknown", name))
+		case name == output.NameFile && c.OutputFile == "":
+			dropped = append(dropped, "file needs output_file")
+		case name == output.NameCommand && c.OutputCommand == "":
+			dropped = append(dropped, "command needs output_command")
+		case name == output.NameHTTP && c.OutputURL == "":
+			dropped = append(dropped, "http needs output_url")
+		default:
+			names = append(names, name)
+		}
+	}
+
+	byFile := len(names) == 0 && c.OutputFile != ""
+	if len(names) == 0 {
+		names = []string{output.NameKeyboard}
+		if byFile {
+			names = []string{output.NameFile}
+		}
+	}
+	c.Output = strings.Join(names, ",")
+	r.Set("output", c.Output, r.Source("output"))
+	if byFile {
+		r.Note("output", c.Output, "output_file is set")
+	}
+	if len(dropped) > 0 {
+		log.Printf("main: ignoring outputs: %s", strings.Join(dropped, ", "))
+		r.Note("output", c.Output, "ignored "+strings.Join(dropped, ", "))
+	}
+}
+
+// hasOutput reports whether the sink called name is one of the outputs.
+func hasOutput(config ListenConfig, name string) bool {
+	return slices.Contains(strings.Split(config.Output, ","), name)
+}
+
+// typesText reports whether transcripts are entered into the focused
+// window, so keys can be pressed and text erased there.
+func typesText(config ListenConfig) bool {
+	return hasOutput(config, output.NameKeyboard) || hasOutput(config, output.NameClipboard)
+}
+
+// newOutput returns the configured output sinks and the keyboard sink, if
+// it is one of them. Sinks which cannot be created are logged and left out.
+func newOutput(config ListenConfig) (output.Multi, *output.Keyboard) {
+	var sinks output.Multi
+	var keyboard *output.Keyboard
+	for _, name := range strings.Split(config.Output, ",") {
+		switch name {
+		case output.NameKeyboard, output.NameClipboard:
+			if config.InputController == nil {
+				log.Printf("newOutput: no input controller available, cannot use the %s output", name)
+				continue
+			}
+			if name == output.NameKeyboard {
+				keyboard = output.NewKeyboard(config.InputController)
+				sinks = append(sinks, keyboard)
+				continue
+			}
+			clipboard, err := output.NewClipboard(config.InputController)
+			if err != nil {
+				log.Println("newOutput: ", err)
+				continue
+			}
+			sinks = append(sinks, clipboard)
+		case output.NameFile:
+			sinks = append(sinks, output.File{Path: config.OutputFile})
+		case output.NameStdout:
+			sinks = append(sinks, output.NewWriter(os.Stdout))
+		case output.NameCommand:
+			sinks = append(sinks, output.Command{Command: config.OutputCommand})
+		case output.NameHTTP:
+			sinks = append(sinks, output.NewHTTP(config.OutputURL))
+		}
+	}
+	return sinks, keyboard
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log"
+
+	"github.com/richiejp/VoxInput/internal/postproc"
+)
+
+// loadPostprocessor returns the post-processing pipeline from the
+// configured file, which is read for each session so edits take effect
+// without a reload. It returns an empty pipeline, which leaves transcripts
+// as they are, if there is no file or it is invalid.
+func loadPostprocessor(config ListenConfig) postproc.Pipeline {
+	if config.PostprocessFile == "" {
+		return nil
+	}
+	p, err := postproc.Load(config.PostprocessFile)
+	if err != nil {
+		log.Println("loadPostprocessor: not post-processing transcripts: ", err)
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/input"
+	"github.com/richiejp/VoxInput/internal/localvqe"
+)
+
+// reloadField describes how one setting behaves when the daemon reloads its
+// configuration. Fields with restart set are only reported; the running
+// daemon keeps its old value because the capture stream, AEC engine or
+// sockets were built from it at startup.
+type reloadField struct {
+	key     string
+	restart bool
+	secret  bool
+	value   func(o *listenOptions) any
+	apply   func(dst, src *listenOptions)
+}
+
+func reloadable[T comparable](key string, restart bool, field func(o *listenOptions) *T) reloadField {
+	return reloadField{
+		key:     key,
+		restart: restart,
+		value:   func(o *listenOptions) any { return *field(o) },
+		apply:   func(dst, src *listenOptions) { *field(dst) = *field(src) },
+	}
+}
+
+// redacted hides the value of a secret setting in the reload report.
+func (f reloadField) redacted() reloadField {
+	f.secret = true
+	return f
+}
+
+// reloadFields uses the same keys as the config file profiles.
+var reloadFields = []reloadField{
+	reloadable("api_key", false, func(o *listenOptions) *string { return &o.Config.APIKey }).redacted(),
+	reloadable("base_url", false, func(o *listenOptions) *string { return &o.Config.HTTPAPIBase }),
+	reloadable("ws_base_url", false, func(o *listenOptions) *string { return &o.Config.WSAPIBase }),
+	reloadable("lang", false, func(o *listenOptions) *string { return &o.Config.Lang }),
+	reloadable("transcription_model", false, func(o *listenOptions) *string { return &o.Config.Model }),
+	reloadable("assistant_model", false, func(o *listenOptions) *string { return &o.Config.AssistantModel }),
+	reloadable("assistant_voice", false, func(o *listenOptions) *string { return &o.Config.AssistantVoice }),
+	reloadable("assistant_instructions", false, func(o *listenOptions) *string { return &o.Config.Instructions }),
+	reloadable("assistant_enable_dotool", false, func(o *listenOptions) *bool { return &o.Config.EnableDotool }),
+	reloadable("transcription_timeout", false, func(o *listenOptions) *time.Duration { return &o.Config.Timeout }),
+	reloadable("prompt", false, func(o *listenOptions) *string { return &o.Config.Prompt }),
+	reloadable("output_file", false, func(o *listenOptions) *string { return &o.Config.OutputFile }),
+	reloadable("output", false, func(o *listenOptions) *string { return &o.Config.Output }),
+	reloadable("output_command", false, func(o *listenOptions) *string { return &o.Config.OutputCommand }),
+	reloadable("output_url", false, func(o *listenOptions) *string { return &o.Config.OutputURL }),
+	reloadable("code_word_start", false, func(o *listenOptions) *string { return &o.Config.CodeWordStart }),
+	reloadable("code_word_stop", false, func(o *listenOptions) *string { return &o.Config.CodeWordStop }),
+	reloadable("voice_commands", false, func(o *listenOptions) *bool { return &o.Config.VoiceCommands }),
+	reloadable("voice_commands_file", false, func(o *listenOptions) *string { return &o.Config.VoiceCommandsFile }),
+	reloadable("type_partial", false, func(o *listenOptions) *bool { return &o.Config.TypePartial }),
+	reloadable("history", false, func(o *listenOptions) *bool { return &o.Config.History }),
+	reloadable("history_file", false, func(o *listenOptions) *string { return &o.Config.HistoryFile }),
+	reloadable("undo_max_chars", false, func(o *listenOptions) *int { return &o.Config.UndoMaxChars }),
+	reloadable("undo_timeout", false, func(o *listenOptions) *time.Duration { return &o.Config.UndoTimeout }),
+	reloadable("undo_focus_command", false, func(o *listenOptions) *string { return &o.Config.UndoFocusCommand }),
+	reloadable("postprocess_file", false, func(o *listenOptions) *string { return &o.Config.PostprocessFile }),
+	reloadable("correction", false, func(o *listenOptions) *bool { return &o.Config.Correction }),
+	reloadable("correction_model", false, func(o *listenOptions) *string { return &o.Config.CorrectionModel }),
+	reloadable("correction_glossary", false, func(o *listenOptions) *string { return &o.Config.CorrectionGlossary }),
+	reloadable("correction_timeout", false, func(o *listenOptions) *time.Duration { return &o.Config.CorrectionTimeout }),
+	reloadable("dump_audio_dir", false, func(o *listenOptions) *string { return &o.Config.DumpAudioDir }),
+	reloadable("assistant_screenshot_command", false, func(o *listenOptions) *string { return &o.Config.ScreenshotCommand }),
+	reloadable("assistant_screenshot_file", false, func(o *listenOptions) *string { return &o.Config.ScreenshotFile }),
+	reloadable("mode", false, func(o *listenOptions) *string { return &o.Config.Mode }),
+
+	reloadable("capture_device", true, func(o *listenOptions) *string { return &o.Config.CaptureDevice }),
+	reloadable("input_sample_rate", true, func(o *listenOptions) *int { return &o.Config.InputSampleRate }),
+	reloadable("output_sample_rate", true, func(o *listenOptions) *int { return &o.Config.OutputSampleRate }),
+	reloadable("enable_aec", true, func(o *listenOptions) *bool { return &o.Config.EnableAEC }),
+	reloadable("localvqe_model", true, func(o *listenOptions) *string { return &o.Config.LocalVQEModelPath }),
+	reloadable("localvqe_model_version", true, func(o *listenOptions) *localvqe.ModelVariant { return &o.Config.LocalVQEModelVersion }),
+	reloadable("localvqe_lib", true, func(o *listenOptions) *string { return &o.Config.LocalVQELibPath }),
+	reloadable("aec_ref_source", true, func(o *listenOptions) *AECRefSource { return &o.Config.AECRefSource }),
+	reloadable("aec_monitor_device", true, func(o *listenOptions) *string { return &o.Config.AECMonitorDevice }),
+	reloadable("aec_noise_gate", true, func(o *listenOptions) *bool { return &o.Config.AECNoiseGate }),
+	reloadable("aec_noise_gate_dbfs", true, func(o *listenOptions) *float32 { return &o.Config.AECNoiseGateDBFS }),
+	reloadable("show_status", true, func(o *listenOptions) *bool { return &o.ShowStatus }),
+	reloadable("instance", true, func(o *listenOptions) *string { return &o.Instance }),
+	reloadable("socket", true, func(o *listenOptions) *string { return &o.SocketPath }),
+	reloadable("dbus", true, func(o *listenOptions) *bool { return &o.DBus }),
+	reloadable("http_addr", true, func(o *listenOptions) *string { return &o.HTTPAddr }),
+	reloadable("http_token", true, func(o *listenOptions) *string { return &o.HTTPToken }).redacted(),
+	reloadable("metrics_addr", true, func(o *listenOptions) *string { return &o.MetricsAddr }),
+	reloadable("replay", true, func(o *listenOptions) *bool { return &o.Replay }),
+	reloadable("realtime", true, func(o *listenOptions) *bool { return &o.Realtime }),
+}
+
+// reloader re-resolves the listen settings from the original command line,
+// the environment and the config file, which may have been edited since
+// the daemon started.
+type reloader struct {
+	args []string
+	opts listenOptions
+}
+
+func newReloader(args []string, opts listenOptions) *reloader {
+	return &reloader{args: args, opts: opts}
+}
+
+// Reload returns config with every setting that can change between
+// sessions and has changed since it was last read updated, and logs which
+// settings changed and which of those need a restart to take effect.
+// Settings changed at runtime through IPC are kept unless the new settings
+// change them too.
+func (rl *reloader) Reload(config ListenConfig) (ListenConfig, error) {
+	next, _, err := resolveListenOptions(rl.args)
+	if err != nil {
+		return config, err
+	}
+
+	cur := rl.opts
+	live := listenOptions{Config: config}
+	var applied, restart int
+	for _, f := range reloadFields {
+		oldVal, newVal := f.value(&cur), f.value(&next)
+		if oldVal == newVal {
+			continue
+		}
+		change := fmt.Sprintf("%v -> %v", oldVal, newVal)
+		if f.secret {
+			change = "changed"
+		}
+		if f.restart {
+			restart++
+			log.Printf("reload: %s: %s (requires restart, keeping old value)", f.key, change)
+			continue
+		}
+		applied++
+		f.apply(&cur, &next)
+		f.apply(&live, &next)
+		log.Printf("reload: %s: %s (applies to the next session)", f.key, change)
+	}
+
+	// The input controller is only created at startup when text is typed
+	// or dotool is enabled, so create it now if the new settings need it.
+	needsInput := typesText(live.Config) || live.Config.EnableDotool
+	if needsInput && live.Config.InputController == nil {
+		ctrl, err := input.New()
+		if err != nil {
+			return config, fmt.Errorf("create input controller: %w", err)
+		}
+		live.Config.InputController = ctrl
+	}
+
+	rl.opts = cur
+	log.Printf("reload: %d setting(s) applied, %d require a restart", applied, restart)
+	return live.Config, nil
+}
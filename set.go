@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/ipc"
+)
+
+// listenSettings returns the settings of config which can be changed with
+// the IPC set command.
+func listenSettings(config ListenConfig) ipc.Settings {
+	return ipc.Settings{
+		Lang:         config.Lang,
+		Prompt:       config.Prompt,
+		Model:        config.Model,
+		Mode:         config.Mode,
+		Instructions: config.Instructions,
+	}
+}
+
+// applySet returns config with the settings present in args changed.
+func applySet(config ListenConfig, args ipc.SetArgs) (ListenConfig, error) {
+	if args.Mode != nil && *args.Mode != "transcription" && *args.Mode != "assistant" {
+		return config, fmt.Errorf("invalid mode %q (expected transcription or assistant)", *args.Mode)
+	}
+
+	if args.Lang != nil {
+		config.Lang = *args.Lang
+	}
+	if args.Prompt != nil {
+		config.Prompt = *args.Prompt
+	}
+	if args.Model != nil {
+		config.Model = *args.Model
+	}
+	if args.Mode != nil {
+		config.Mode = *args.Mode
+	}
+	if args.Instructions != nil {
+		config.Instructions = *args.Instructions
+	}
+	return config, nil
+}
+
+// handleSet answers an IPC set command and returns the config for the next
+// session. When l is the running session, its language, prompt, model and
+// instructions are updated live with a session.update; a new mode only
+// applies from the next session.
+func handleSet(cmd ipc.Command, config ListenConfig, l *Listener) ListenConfig {
+	var args ipc.SetArgs
+	if err := cmd.DecodeArgs(&args); err != nil {
+		cmd.ReplyError(err)
+		return config
+	}
+	next, err := applySet(config, args)
+	if err != nil {
+		cmd.ReplyError(err)
+		return config
+	}
+
+	var notes []string
+	if l != nil {
+		if err := l.UpdateSettings(next); err != nil {
+			log.Println("listen: failed to update the running session: ", err)
+			notes = append(notes, "the running session could not be updated, the settings apply from the next recording")
+		}
+		if next.Mode != l.config.Mode {
+			notes = append(notes, "the mode applies from the next recording")
+		}
+	} else if next.Mode != config.Mode {
+		notes = append(notes, "the mode applies from the next recording")
+	}
+
+	settings := listenSettings(next)
+	log.Printf("listen: settings changed: lang=%q prompt=%q transcription_model=%q mode=%q",
+		settings.Lang, settings.Prompt, settings.Model, settings.Mode)
+	broadcastSettings(next)
+	cmd.Reply(ipc.Event{Text: strings.Join(notes, "; "), Settings: &settings})
+	return next
+}
+
+// broadcastSettings tells IPC clients about the current settings and
+// capabilities.
+func broadcastSettings(config ListenConfig) {
+	if config.IPCServer == nil {
+		return
+	}
+	config.IPCServer.SetCapabilities(ipcCapabilities(config))
+	settings := listenSettings(config)
+	config.IPCServer.Broadcast(ipc.Event{
+		Kind:     ipc.EventSettings,
+		Ts:       time.Now().UnixMilli(),
+		Settings: &settings,
+	})
+}
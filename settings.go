@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/codeword"
+	"github.com/richiejp/VoxInput/internal/config"
+	"github.com/richiejp/VoxInput/internal/ipc"
+	"github.com/richiejp/VoxInput/internal/localvqe"
+	"github.com/richiejp/VoxInput/internal/pid"
+)
+
+// listenOptions is everything `listen` resolves from flags, the environment
+// and the config profile. Config lacks the runtime objects (UI, input
+// controller, IPC server) which main creates afterwards.
+type listenOptions struct {
+	Config      ListenConfig
+	ShowStatus  bool
+	Replay      bool
+	Realtime    bool
+	SocketPath  string
+	Instance    string
+	DBus        bool
+	HTTPAddr    string
+	HTTPToken   string
+	MetricsAddr string
+}
+
+var instanceSetting = config.Setting{Key: "instance", Env: []string{"VOXINPUT_INSTANCE"}, Flag: "--instance"}
+
+// resolveInstance returns the listener instance selected with --instance,
+// VOXINPUT_INSTANCE or the config profile, "" being the default instance.
+func resolveInstance(args []string) (string, error) {
+	r, err := config.NewResolver(args)
+	if err != nil {
+		return "", err
+	}
+	return instanceFrom(r)
+}
+
+func instanceFrom(r *config.Resolver) (string, error) {
+	instance := r.String(instanceSetting)
+	if err := pid.ValidateInstance(instance); err != nil {
+		return "", err
+	}
+	return instance, nil
+}
+
+// resolveListenOptions resolves the `listen` settings with the precedence
+// flag > env > profile > default. The returned resolver records where each
+// value came from.
+func resolveListenOptions(args []string) (listenOptions, *config.Resolver, error) {
+	r, err := config.NewResolver(args)
+	if err != nil {
+		return listenOptions{}, nil, err
+	}
+
+	var opts listenOptions
+	c := &opts.Config
+
+	c.APIKey = r.String(config.Setting{
+		Key: "api_key", Env: []string{"VOXINPUT_API_KEY", "OPENAI_API_KEY"}, Default: "sk-xxx", Secret: true})
+	c.HTTPAPIBase = r.String(config.Setting{
+		Key: "base_url", Env: []string{"VOXINPUT_BASE_URL", "OPENAI_BASE_URL"}, Default: "http://localhost:8080/v1"})
+	c.WSAPIBase = r.String(config.Setting{
+		Key: "ws_base_url", Env: []string{"VOXINPUT_WS_BASE_URL", "OPENAI_WS_BASE_URL"}, Default: "ws://localhost:8080/v1/realtime"})
+
+	// VOXINPUT_LANG takes precedence and is used as-is to allow setting language names that diverge from OpenAI's for e.g. Qwen ASR uses names like "English"
+	// LANG is truncated to first 2 characters which matches OpenAI's use of language codes (I hope)
+	c.Lang = r.String(config.Setting{Key: "lang", Env: []string{"VOXINPUT_LANG"}})
+	if r.Source("lang").Kind == config.SourceDefault {
+		if langEnv := os.Getenv("LANG"); langEnv != "" && len(langEnv) >= 2 {
+			c.Lang = langEnv[:2]
+			r.Set("lang", c.Lang, config.Source{Kind: config.SourceEnv, Name: "LANG"})
+			r.Note("lang", c.Lang, fmt.Sprintf("first 2 characters of %q", langEnv))
+		}
+	}
+	if c.Lang != "" {
+		log.Println("main: language is set to ", c.Lang)
+	}
+
+	c.Model = r.String(config.Setting{
+		Key: "transcription_model", Env: []string{"VOXINPUT_TRANSCRIPTION_MODEL", "TRANSCRIPTION_MODEL"}})
+	c.AssistantModel = r.String(config.Setting{
+		Key: "assistant_model", Env: []string{"VOXINPUT_ASSISTANT_MODEL", "ASSISTANT_MODEL"}, Default: "gpt-realtime"})
+	c.AssistantVoice = r.String(config.Setting{
+		Key: "assistant_voice", Env: []string{"VOXINPUT_ASSISTANT_VOICE", "ASSISTANT_VOICE"}})
+	c.Instructions = r.String(config.Setting{
+		Key: "assistant_instructions", Env: []string{"VOXINPUT_ASSISTANT_INSTRUCTIONS", "ASSISTANT_INSTRUCTIONS"}, Flag: "--instructions"})
+	c.EnableDotool = r.Bool(config.Setting{
+		Key: "assistant_enable_dotool", Env: []string{"VOXINPUT_ASSISTANT_ENABLE_DOTOOL"}, Off: "--no-dotool", Default: "yes"})
+	c.EnableAEC = r.Bool(config.Setting{
+		Key: "enable_aec", Env: []string{"VOXINPUT_ENABLE_AEC"}, Off: "--no-aec", Default: "yes"})
+
+	timeoutStr := r.String(config.Setting{
+		Key: "transcription_timeout", Env: []string{"VOXINPUT_TRANSCRIPTION_TIMEOUT", "TRANSCRIPTION_TIMEOUT"}, Default: "30s"})
+	c.Timeout, err = time.ParseDuration(timeoutStr)
+	if err != nil {
+		log.Println("main: failed to parse timeout", err)
+		c.Timeout = time.Second * 30
+		r.Note("transcription_timeout", c.Timeout.String(), fmt.Sprintf("invalid value %q", timeoutStr))
+	}
+
+	opts.ShowStatus = r.Bool(config.Setting{
+		Key: "show_status", Env: []string{"VOXINPUT_SHOW_STATUS", "SHOW_STATUS"}, Off: "--no-show-status", Default: "yes"})
+	c.CaptureDevice = r.String(config.Setting{
+		Key: "capture_device", Env: []string{"VOXINPUT_CAPTURE_DEVICE"}})
+	c.Prompt = r.String(config.Setting{
+		Key: "prompt", Env: []string{"VOXINPUT_PROMPT"}, Flag: "--prompt"})
+	c.OutputFile = r.String(config.Setting{
+		Key: "output_file", Env: []string{"VOXINPUT_OUTPUT_FILE"}, Flag: "--output-file"})
+	c.OutputCommand = r.String(config.Setting{
+		Key: "output_command", Env: []string{"VOXINPUT_OUTPUT_COMMAND"}, Flag: "--output-command"})
+	c.OutputURL = r.String(config.Setting{
+		Key: "output_url", Env: []string{"VOXINPUT_OUTPUT_URL"}, Flag: "--output-url"})
+	resolveOutput(r, c)
+	c.CodeWordStart = r.String(config.Setting{
+		Key: "code_word_start", Env: []string{"VOXINPUT_CODE_WORD_START"}, Flag: "--code-word-start"})
+	c.CodeWordStop = r.String(config.Setting{
+		Key: "code_word_stop", Env: []string{"VOXINPUT_CODE_WORD_STOP"}, Flag: "--code-word-stop"})
+	var codeWordsIgnored string
+	switch {
+	case (c.CodeWordStart == "") != (c.CodeWordStop == ""):
+		codeWordsIgnored = "both code words must be set"
+	case c.CodeWordStart != "" && (!codeword.Valid(c.CodeWordStart) || !codeword.Valid(c.CodeWordStop)):
+		// Such a phrase is never heard, so every transcript would be dropped
+		codeWordsIgnored = "code words need a letter or digit"
+	}
+	if codeWordsIgnored != "" {
+		log.Printf("main: %s, ignoring them", codeWordsIgnored)
+		for _, key := range []string{"code_word_start", "code_word_stop"} {
+			r.Note(key, "", "ignored, "+codeWordsIgnored)
+		}
+		c.CodeWordStart, c.CodeWordStop = "", ""
+	}
+	c.VoiceCommands = r.Bool(config.Setting{
+		Key: "voice_commands", Env: []string{"VOXINPUT_VOICE_COMMANDS"}, On: "--voice-commands", Off: "--no-voice-commands", Default: "no"})
+	c.VoiceCommandsFile = r.String(config.Setting{
+		Key: "voice_commands_file", Env: []string{"VOXINPUT_VOICE_COMMANDS_FILE"}, Flag: "--voice-commands-file"})
+	c.TypePartial = r.Bool(config.Setting{
+		Key: "type_partial", Env: []string{"VOXINPUT_TYPE_PARTIAL"}, On: "--type-partial", Off: "--no-type-partial", Default: "no"})
+	c.History = r.Bool(historySetting)
+	c.HistoryFile = r.String(historyFileSetting())
+	c.PostprocessFile = r.String(config.Setting{
+		Key: "postprocess_file", Env: []string{"VOXINPUT_POSTPROCESS_FILE"}, Flag: "--postprocess-file"})
+	c.Correction = r.Bool(config.Setting{
+		Key: "correction", Env: []string{"VOXINPUT_CORRECTION"}, On: "--correction", Off: "--no-correction", Default: "no"})
+	c.CorrectionModel = r.String(config.Setting{
+		Key: "correction_model", Env: []string{"VOXINPUT_CORRECTION_MODEL"}, Flag: "--correction-model", Default: "gpt-4o-mini"})
+	c.CorrectionGlossary = r.String(config.Setting{
+		Key: "correction_glossary", Env: []string{"VOXINPUT_CORRECTION_GLOSSARY"}, Flag: "--correction-glossary"})
+
+	correctionTimeoutStr := r.String(config.Setting{
+		Key: "correction_timeout", Env: []string{"VOXINPUT_CORRECTION_TIMEOUT"}, Default: "5s"})
+	c.CorrectionTimeout, err = time.ParseDuration(correctionTimeoutStr)
+	if err != nil {
+		log.Println("main: failed to parse correction timeout", err)
+		c.CorrectionTimeout = time.Second * 5
+		r.Note("correction_timeout", c.CorrectionTimeout.String(), fmt.Sprintf("invalid value %q", correctionTimeoutStr))
+	}
+
+	undoMaxCharsStr := r.String(config.Setting{
+		Key: "undo_max_chars", Env: []string{"VOXINPUT_UNDO_MAX_CHARS"}, Default: "500"})
+	c.UndoMaxChars, err = strconv.Atoi(undoMaxCharsStr)
+	if err != nil || c.UndoMaxChars < 0 {
+		log.Println("main: failed to parse undo max chars", err)
+		c.UndoMaxChars = 500
+		r.Note("undo_max_chars", "500", fmt.Sprintf("invalid value %q", undoMaxCharsStr))
+	}
+
+	undoTimeoutStr := r.String(config.Setting{
+		Key: "undo_timeout", Env: []string{"VOXINPUT_UNDO_TIMEOUT"}, Default: "60s"})
+	c.UndoTimeout, err = time.ParseDuration(undoTimeoutStr)
+	if err != nil {
+		log.Println("main: failed to parse undo timeout", err)
+		c.UndoTimeout = time.Minute
+		r.Note("undo_timeout", c.UndoTimeout.String(), fmt.Sprintf("invalid value %q", undoTimeoutStr))
+	}
+	c.UndoFocusCommand = r.String(config.Setting{
+		Key: "undo_focus_command", Env: []string{"VOXINPUT_UNDO_FOCUS_COMMAND"}})
+
+	inputSampleRateStr := r.String(config.Setting{
+		Key: "input_sample_rate", Env: []string{"VOXINPUT_INPUT_SAMPLE_RATE"}, Default: "24000"})
+	c.InputSampleRate, err = strconv.Atoi(inputSampleRateStr)
+	if err != nil {
+		log.Println("main: failed to parse input sample rate", err)
+		c.InputSampleRate = 24000
+		r.Note("input_sample_rate", "24000", fmt.Sprintf("invalid value %q", inputSampleRateStr))
+	}
+
+	outputSampleRateStr := r.String(config.Setting{
+		Key: "output_sample_rate", Env: []string{"VOXINPUT_OUTPUT_SAMPLE_RATE"}, Default: "24000"})
+	c.OutputSampleRate, err = strconv.Atoi(outputSampleRateStr)
+	if err != nil {
+		log.Println("main: failed to parse output sample rate", err)
+		c.OutputSampleRate = 24000
+		r.Note("output_sample_rate", "24000", fmt.Sprintf("invalid value %q", outputSampleRateStr))
+	}
+
+	c.DumpAudioDir = r.String(config.Setting{
+		Key: "dump_audio_dir", Env: []string{"VOXINPUT_DUMP_AUDIO_DIR"}, Flag: "--dump-audio"})
+	c.LocalVQEModelPath = r.String(config.Setting{
+		Key: "localvqe_model", Env: []string{"VOXINPUT_LOCALVQE_MODEL"}})
+	c.LocalVQEModelVersion = localvqe.ModelVariant(r.String(config.Setting{
+		Key: "localvqe_model_version", Env: []string{"VOXINPUT_LOCALVQE_MODEL_VERSION"}}))
+	c.LocalVQELibPath = r.String(config.Setting{
+		Key: "localvqe_lib", Env: []string{"VOXINPUT_LOCALVQE_LIB"}})
+
+	aecRefSourceStr := r.String(config.Setting{
+		Key: "aec_ref_source", Env: []string{"VOXINPUT_AEC_REF_SOURCE"}, Flag: "--aec-ref-source", Default: "playback"})
+	switch aecRefSourceStr {
+	case string(AECRefPlayback):
+		c.AECRefSource = AECRefPlayback
+	case string(AECRefMonitor):
+		c.AECRefSource = AECRefMonitor
+	default:
+		return listenOptions{}, nil, fmt.Errorf("invalid --aec-ref-source %q (expected %q or %q)",
+			aecRefSourceStr, AECRefPlayback, AECRefMonitor)
+	}
+
+	c.AECMonitorDevice = r.String(config.Setting{
+		Key: "aec_monitor_device", Env: []string{"VOXINPUT_AEC_MONITOR_DEVICE"}, Flag: "--aec-monitor-device"})
+	c.AECNoiseGate = r.Bool(config.Setting{
+		Key: "aec_noise_gate", Env: []string{"VOXINPUT_AEC_NOISE_GATE"}, On: "--aec-noise-gate", Off: "--no-aec-noise-gate", Default: "no"})
+
+	aecNoiseGateDBFSStr := r.String(config.Setting{
+		Key: "aec_noise_gate_dbfs", Env: []string{"VOXINPUT_AEC_NOISE_GATE_DBFS"}, Flag: "--aec-noise-gate-dbfs", Default: "-45.0"})
+	aecNoiseGateDBFS64, err := strconv.ParseFloat(aecNoiseGateDBFSStr, 32)
+	if err != nil {
+		log.Printf("main: failed to parse VOXINPUT_AEC_NOISE_GATE_DBFS=%q, using -45.0: %v", aecNoiseGateDBFSStr, err)
+		aecNoiseGateDBFS64 = -45.0
+		r.Note("aec_noise_gate_dbfs", "-45.0", fmt.Sprintf("invalid value %q", aecNoiseGateDBFSStr))
+	}
+	c.AECNoiseGateDBFS = float32(aecNoiseGateDBFS64)
+
+	c.Mode = r.String(config.Setting{
+		Key: "mode", Env: []string{"VOXINPUT_MODE"}, Flag: "--mode", Default: "transcription"})
+	opts.Instance, err = instanceFrom(r)
+	if err != nil {
+		return listenOptions{}, nil, err
+	}
+	// Named instances always have a socket so they can be told apart by
+	// clients; the default instance keeps it opt-in.
+	var defaultSocket string
+	if opts.Instance != "" {
+		defaultSocket = ipc.SocketPath(opts.Instance)
+	}
+	opts.SocketPath = r.String(config.Setting{
+		Key: "socket", Env: []string{"VOXINPUT_SOCKET"}, Flag: "--socket", Default: defaultSocket})
+	opts.DBus = r.Bool(config.Setting{
+		Key: "dbus", Env: []string{"VOXINPUT_DBUS"}, On: "--dbus", Default: "no"})
+	opts.HTTPAddr = r.String(config.Setting{
+		Key: "http_addr", Env: []string{"VOXINPUT_HTTP_ADDR"}, Flag: "--http-addr"})
+	opts.HTTPToken = r.String(config.Setting{
+		Key: "http_token", Env: []string{"VOXINPUT_HTTP_TOKEN"}, Secret: true})
+	opts.MetricsAddr = r.String(config.Setting{
+		Key: "metrics_addr", Env: []string{"VOXINPUT_METRICS_ADDR"}, Flag: "--metrics-addr"})
+	c.ScreenshotCommand = r.String(config.Setting{
+		Key: "assistant_screenshot_command", Env: []string{"VOXINPUT_ASSISTANT_SCREENSHOT_COMMAND"}, Flag: "--screenshot-command"})
+	c.ScreenshotFile = r.String(config.Setting{
+		Key: "assistant_screenshot_file", Env: []string{"VOXINPUT_ASSISTANT_SCREENSHOT_FILE"}, Flag: "--screenshot-file"})
+
+	opts.Replay = r.Bool(config.Setting{Key: "replay", On: "--replay", Default: "no"})
+	opts.Realtime = r.Bool(config.Setting{Key: "realtime", Off: "--no-realtime", Default: "yes"})
+	if !opts.Realtime {
+		// The D-Bus service and HTTP API serve the realtime listener's
+		// commands, which the non-realtime mode has none of
+		if opts.DBus {
+			log.Println("main: --dbus is not supported with --no-realtime, ignoring it")
+			r.Note("dbus", "no", "ignored with --no-realtime")
+			opts.DBus = false
+		}
+		if opts.HTTPAddr != "" {
+			log.Println("main: --http-addr is not supported with --no-realtime, ignoring it")
+			r.Note("http_addr", "", "ignored with --no-realtime")
+			opts.HTTPAddr = ""
+		}
+	}
+
+	for _, key := range r.Unused() {
+		log.Printf("main: ignoring unknown setting %q in profile %q", key, r.ProfileName())
+	}
+
+	return opts, r, nil
+}
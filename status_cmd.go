@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/config"
+	"github.com/richiejp/VoxInput/internal/ipc"
+)
+
+// stateOffline is shown by status bars while no listener is reachable.
+const stateOffline ipc.State = "offline"
+
+// statusReconnectInterval is how often `status --follow` retries the
+// socket after losing the listener.
+const statusReconnectInterval = time.Second
+
+type barState struct {
+	text    string
+	tooltip string
+	color   string
+}
+
+// barStates gives the label, default tooltip and i3bar colour of each
+// state; the CSS class in waybar output is the state itself.
+var barStates = map[ipc.State]barState{
+	stateOffline:             {"off", "VoxInput is not running", "#888888"},
+	ipc.StateIdle:            {"idle", "Not recording", ""},
+	ipc.StateListening:       {"listening", "Listening with voice audio detection...", "#00ff00"},
+	ipc.StateSpeechDetected:  {"speech", "Detected speech...", "#ffff00"},
+	ipc.StateTranscribing:    {"transcribing", "Transcribing...", "#ffff00"},
+	ipc.StateSpeechSubmitted: {"submitted", "Speech submitted...", "#ffff00"},
+	ipc.StateGenerating:      {"responding", "Generating response...", "#00ffff"},
+	ipc.StateToolCall:        {"tool", "Calling a tool", "#ff00ff"},
+	ipc.StateDictationPaused: {"paused", "Dictation paused", "#ffaa00"},
+}
+
+type waybarStatus struct {
+	Text    string `json:"text"`
+	Alt     string `json:"alt"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+}
+
+type i3barBlock struct {
+	Name      string `json:"name"`
+	Instance  string `json:"instance"`
+	FullText  string `json:"full_text"`
+	ShortText string `json:"short_text"`
+	Color     string `json:"color,omitempty"`
+}
+
+// barStatusCommand implements `status --follow` and `status --format`.
+// It asks the listener for its state over the IPC socket and, with
+// --follow, prints a new line whenever the state changes, reconnecting if
+// the listener goes away.
+func barStatusCommand(args []string) {
+	format, _ := config.FlagValue(args, "--format")
+	if format == "" {
+		format = "plain"
+	}
+	if format != "plain" && format != "waybar" && format != "i3bar" {
+		log.Fatalf("status: unknown --format %q (expected plain, waybar or i3bar)", format)
+	}
+	follow := config.HasFlag(args, "--follow")
+
+	var last string
+	emit := func(state ipc.State, tooltip string) {
+		line := formatBarStatus(format, state, tooltip)
+		if follow && format == "i3bar" {
+			line = "[" + line + "],"
+		}
+		if line != last {
+			fmt.Println(line)
+			last = line
+		}
+	}
+
+	if !follow {
+		client, err := connectStatusClient(args)
+		if err != nil {
+			emit(stateOffline, "")
+			return
+		}
+		defer client.Close()
+		reply, err := client.Request(ipc.Command{Kind: ipc.CommandStatus}, ipc.RequestTimeout)
+		if err != nil {
+			log.Fatalln("status: ", err)
+		}
+		emit(stateFromReply(reply), "")
+		return
+	}
+
+	if format == "i3bar" {
+		fmt.Println(`{"version":1}`)
+		fmt.Println("[")
+	}
+	var lastErr string
+	for {
+		// Only log when the reason changes so retrying stays quiet
+		if err := followStatus(args, emit); err != nil && err.Error() != lastErr {
+			log.Println("status: ", err)
+			lastErr = err.Error()
+		}
+		emit(stateOffline, "")
+		time.Sleep(statusReconnectInterval)
+	}
+}
+
+// followStatus prints the listener's state until the connection is lost.
+func followStatus(args []string, emit func(ipc.State, string)) error {
+	client, err := connectStatusClient(args)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	reply, err := client.Request(ipc.Command{Kind: ipc.CommandStatus}, ipc.RequestTimeout)
+	if err != nil {
+		return err
+	}
+	emit(stateFromReply(reply), "")
+
+	// Subscribing after the status request without replay still delivers
+	// any change made in between
+	kinds := []ipc.EventKind{ipc.EventStatus, ipc.EventFunctionCall}
+	if err := client.Subscribe(kinds, false, 5*time.Second); err != nil {
+		log.Println("status: subscribe failed, filtering locally: ", err)
+	}
+
+	for {
+		e, err := client.ReadEvent()
+		if err != nil {
+			return err
+		}
+		if e.State == "" {
+			continue
+		}
+		emit(e.State, e.Text)
+	}
+}
+
+func connectStatusClient(args []string) (*ipc.Client, error) {
+	socketPath := clientSocketPath(args)
+	if socketPath == "" {
+		return nil, fmt.Errorf("no IPC socket found")
+	}
+	return ipc.Connect(socketPath)
+}
+
+// stateFromReply returns the state in a status reply; daemons predating
+// states only report whether they are recording.
+func stateFromReply(reply ipc.Event) ipc.State {
+	if reply.State != "" {
+		return reply.State
+	}
+	if reply.Recording {
+		return ipc.StateListening
+	}
+	return ipc.StateIdle
+}
+
+func formatBarStatus(format string, state ipc.State, tooltip string) string {
+	bs, ok := barStates[state]
+	if !ok {
+		bs = barState{text: string(state)}
+	}
+	if tooltip == "" {
+		tooltip = bs.tooltip
+	}
+
+	var v any
+	switch format {
+	case "waybar":
+		v = waybarStatus{Text: bs.text, Alt: string(state), Tooltip: tooltip, Class: string(state)}
+	case "i3bar":
+		v = i3barBlock{Name: "voxinput", Instance: string(state), FullText: "VoxInput: " + bs.text, ShortText: bs.text, Color: bs.color}
+	default:
+		return string(state)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Fatalln("status: ", err)
+	}
+	return string(data)
+}
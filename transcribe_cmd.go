@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	openairt "github.com/WqyJh/go-openai-realtime/v2"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/richiejp/VoxInput/internal/audio"
+	"github.com/richiejp/VoxInput/internal/segment"
+)
+
+const (
+	// transcribeChunk is how much audio each realtime append event carries
+	transcribeChunk = 100 * time.Millisecond
+	// transcribeSilence is appended to a file streamed through the realtime
+	// API, so server VAD detects the end of the last utterance
+	transcribeSilence = 2 * time.Second
+	// transcribeIdle is how long to wait for more speech to be detected
+	// once all audio is sent and every utterance is transcribed
+	transcribeIdle = 3 * time.Second
+)
+
+// transcribeCommand implements `voxinput transcribe <file>`, which prints
+// the timestamped transcript of a recording. The file is a WAV file, or
+// raw 16-bit little-endian PCM with --raw, and "-" reads stdin.
+//
+//	--raw             the file is raw PCM
+//	--rate <hz>       sample rate of the raw PCM
+//	--channels <n>    interleaved channels of the raw PCM (default: 1)
+//	--format <name>   text, srt, vtt or json (default: text)
+//
+// The audio is resampled to the input sample rate and streamed through the
+// realtime API with server VAD, or posted to the HTTP API with
+// --no-realtime. Other flags are the same as for listen.
+func transcribeCommand(args []string) {
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && args[0] != "-") {
+		log.Fatalln("transcribe: usage: voxinput transcribe <file> [--raw --rate <hz>] [--format <format>]")
+	}
+	path := args[0]
+	args = args[1:]
+
+	var raw bool
+	var rate int
+	channels := 1
+	format := segment.FormatText
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--rate", "--channels", "--format":
+			if i+1 >= len(args) {
+				log.Fatalf("transcribe: %s requires a value", args[i])
+			}
+			switch args[i] {
+			case "--rate", "--channels":
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					log.Fatalf("transcribe: invalid %s %q", args[i], args[i+1])
+				}
+				if args[i] == "--rate" {
+					rate = n
+				} else {
+					channels = n
+				}
+			case "--format":
+				format = args[i+1]
+			}
+			i++
+		case "--raw":
+			raw = true
+		}
+	}
+	if raw && rate == 0 {
+		log.Fatalln("transcribe: --raw requires --rate")
+	}
+	if !raw && (rate != 0 || channels != 1) {
+		log.Fatalln("transcribe: --rate and --channels only apply to --raw")
+	}
+
+	opts, _, err := resolveListenOptions(args)
+	if err != nil {
+		log.Fatalln("transcribe: ", err)
+	}
+	config := opts.Config
+
+	w, err := segment.NewWriter(os.Stdout, format)
+	if err != nil {
+		log.Fatalln("transcribe: ", err)
+	}
+
+	pcm, err := readTranscribeAudio(path, raw, &rate, &channels)
+	if err != nil {
+		log.Fatalln("transcribe: ", err)
+	}
+	pcm = audio.ResampleS16(audio.DownmixS16(pcm, channels), rate, config.InputSampleRate)
+	log.Printf("transcribe: %s: %d channels at %d Hz, %s of audio", path, channels, rate,
+		pcmDuration(len(pcm), config.InputSampleRate).Round(time.Millisecond))
+
+	ctx := context.Background()
+	postprocessor := loadPostprocessor(config)
+	corrector := newCorrector(config)
+	emit := func(s segment.Segment) error {
+		s.Text = postprocessor.Process(correctTranscript(ctx, corrector, strings.TrimSpace(s.Text)))
+		if s.Text == "" {
+			return nil
+		}
+		return w.Write(s)
+	}
+
+	if opts.Realtime {
+		err = transcribeRealtime(ctx, config, pcm, emit)
+	} else {
+		err = transcribeHTTP(ctx, config, pcm, emit)
+	}
+	if err != nil {
+		log.Fatalln("transcribe: ", err)
+	}
+}
+
+// readTranscribeAudio returns the samples of path as 16-bit PCM, setting
+// rate and channels from the header of a WAV file.
+func readTranscribeAudio(path string, raw bool, rate, channels *int) ([]byte, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if raw {
+		pcm, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		frame := *channels * 2
+		return pcm[:len(pcm)/frame*frame], nil
+	}
+
+	format, pcm, err := audio.ReadWAV(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	*rate, *channels = format.SampleRate, format.Channels
+	return format.ToS16(pcm), nil
+}
+
+// pcmDuration returns how long n bytes of 16-bit mono PCM play for.
+func pcmDuration(n, rate int) time.Duration {
+	return time.Duration(n/2) * time.Second / time.Duration(rate)
+}
+
+// transcribeHTTP posts the audio to the HTTP transcription API in one
+// request, asking for segments with timestamps. A server which does not
+// return segments gives one for the whole file.
+func transcribeHTTP(ctx context.Context, config ListenConfig, pcm []byte, emit func(segment.Segment) error) error {
+	wavHeader := audio.NewWAVHeader(uint32(len(pcm)), uint32(config.InputSampleRate))
+	var headerBuf bytes.Buffer
+	if err := wavHeader.Write(&headerBuf); err != nil {
+		return fmt.Errorf("write wav header: %w", err)
+	}
+
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	clientConfig.BaseURL = config.HTTPAPIBase
+	clientConfig.HTTPClient = &http.Client{
+		Timeout: config.Timeout,
+	}
+	client := openai.NewClientWithConfig(clientConfig)
+	resp, err := client.CreateTranscription(ctx, openai.AudioRequest{
+		Model:    config.Model,
+		FilePath: "audio.wav",
+		Reader:   io.MultiReader(&headerBuf, bytes.NewReader(pcm)),
+		Language: config.Lang,
+		Prompt:   config.Prompt,
+		Format:   openai.AudioResponseFormatVerboseJSON,
+	})
+	if err != nil {
+		return fmt.Errorf("CreateTranscription: %w", err)
+	}
+
+	if len(resp.Segments) == 0 {
+		return emit(segment.Segment{End: pcmDuration(len(pcm), config.InputSampleRate), Text: resp.Text})
+	}
+	seconds := func(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
+	for _, s := range resp.Segments {
+		if err := emit(segment.Segment{Start: seconds(s.Start), End: seconds(s.End), Text: s.Text}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transcribeRealtime streams the audio through a realtime transcription
+// session with server VAD, emitting a segment for each utterance the
+// server detects. Its timestamps are the offsets the server reports.
+func transcribeRealtime(ctx context.Context, config ListenConfig, pcm []byte, emit func(segment.Segment) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	initCtx, finishInit := context.WithTimeout(ctx, config.Timeout)
+	defer finishInit()
+	var connOpts []openairt.ConnectOption
+	if config.AssistantModel != "" {
+		connOpts = append(connOpts, openairt.WithModel(config.AssistantModel))
+	}
+	conn, err := newRealtimeClient(config).Connect(initCtx, connOpts...)
+	if err != nil {
+		return fmt.Errorf("realtime connect: %w", err)
+	}
+	defer conn.Close()
+	if err := waitForSessionUpdated(initCtx, conn); err != nil {
+		return fmt.Errorf("session not created: %w", err)
+	}
+	update := transcriptionSessionUpdate(config)
+	update.EventID = "Transcribe file"
+	if err := conn.SendMessage(initCtx, update); err != nil {
+		return fmt.Errorf("session update: %w", err)
+	}
+	if err := waitForSessionUpdated(initCtx, conn); err != nil {
+		return fmt.Errorf("session not updated: %w", err)
+	}
+	if initCtx.Err() != nil {
+		return fmt.Errorf("session not updated: %w", initCtx.Err())
+	}
+	finishInit()
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- sendFileAudio(ctx, conn, pcm, config.InputSampleRate)
+	}()
+
+	msgs := make(chan openairt.ServerEvent)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := conn.ReadMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				var permanent *openairt.PermanentError
+				if errors.As(err, &permanent) {
+					readErr <- err
+					return
+				}
+				log.Println("transcribeRealtime: error receiving message, retrying: ", err)
+				continue
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// pending holds the start of each utterance not yet transcribed and
+	// ends the end of those the server has seen all of
+	pending := make(map[string]time.Duration)
+	ends := make(map[string]time.Duration)
+	var lastEnd time.Duration
+	wait := func() time.Duration {
+		if len(pending) == 0 {
+			return transcribeIdle
+		}
+		return config.Timeout
+	}
+	// The timer only runs once all audio is sent
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	sending := true
+
+	for {
+		select {
+		case err := <-sent:
+			if err != nil {
+				return fmt.Errorf("send audio: %w", err)
+			}
+			sending = false
+			timer.Reset(wait())
+			continue
+		case err := <-readErr:
+			return fmt.Errorf("connection failed: %w", err)
+		case <-timer.C:
+			if len(pending) > 0 {
+				return fmt.Errorf("timed out waiting for %d transcripts", len(pending))
+			}
+			return nil
+		case msg := <-msgs:
+			switch msg.ServerEventType() {
+			case openairt.ServerEventTypeInputAudioBufferSpeechStarted:
+				ev := msg.(openairt.InputAudioBufferSpeechStartedEvent)
+				pending[ev.ItemID] = time.Duration(ev.AudioStartMs) * time.Millisecond
+			case openairt.ServerEventTypeInputAudioBufferSpeechStopped:
+				ev := msg.(openairt.InputAudioBufferSpeechStoppedEvent)
+				ends[ev.ItemID] = time.Duration(ev.AudioEndMs) * time.Millisecond
+			case openairt.ServerEventTypeConversationItemInputAudioTranscriptionCompleted:
+				ev := msg.(openairt.ConversationItemInputAudioTranscriptionCompletedEvent)
+				start, ok := pending[ev.ItemID]
+				if !ok {
+					start = lastEnd
+				}
+				end := max(ends[ev.ItemID], start)
+				delete(pending, ev.ItemID)
+				delete(ends, ev.ItemID)
+				lastEnd = end
+				if err := emit(segment.Segment{Start: start, End: end, Text: ev.Transcript}); err != nil {
+					return err
+				}
+			case openairt.ServerEventTypeConversationItemInputAudioTranscriptionFailed:
+				ev := msg.(openairt.ConversationItemInputAudioTranscriptionFailedEvent)
+				log.Printf("transcribeRealtime: transcription of the utterance at %s failed: %s",
+					pending[ev.ItemID], ev.Error.Message)
+				delete(pending, ev.ItemID)
+				delete(ends, ev.ItemID)
+			case openairt.ServerEventTypeError:
+				log.Println("transcribeRealtime: server error: ", msg.(openairt.ErrorEvent).Error.Message)
+			}
+			if !sending {
+				timer.Reset(wait())
+			}
+		}
+	}
+}
+
+// sendFileAudio appends the audio, followed by silence, to the input audio
+// buffer of the session in chunks.
+func sendFileAudio(ctx context.Context, conn *openairt.Conn, pcm []byte, rate int) error {
+	chunk := rate * int(transcribeChunk/time.Millisecond) / 1000 * 2
+	pcm = append(pcm, make([]byte, rate*2*int(transcribeSilence/time.Second))...)
+	for off := 0; off < len(pcm); off += chunk {
+		end := min(off+chunk, len(pcm))
+		if err := conn.SendMessage(ctx, openairt.InputAudioBufferAppendEvent{
+			Audio: base64.StdEncoding.EncodeToString(pcm[off:end]),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,27 +5,29 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"time"
 
 	openairt "github.com/WqyJh/go-openai-realtime/v2"
 	"github.com/richiejp/VoxInput/internal/audio"
 	"github.com/richiejp/VoxInput/internal/gui"
+	"github.com/richiejp/VoxInput/internal/history"
+	"github.com/richiejp/VoxInput/internal/output"
+	"github.com/richiejp/VoxInput/internal/voicecmd"
 )
 
-func (l *Listener) startTranscriptionSession(ctx context.Context) error {
+// transcriptionSessionUpdate configures a realtime session to transcribe
+// the input audio with server VAD.
+func transcriptionSessionUpdate(config ListenConfig) openairt.SessionUpdateEvent {
 	var transcription *openairt.AudioTranscription
-	if l.config.Model != "" {
+	if config.Model != "" {
 		transcription = &openairt.AudioTranscription{
-			Model:    l.config.Model,
-			Language: l.config.Lang,
-			Prompt:   l.config.Prompt,
+			Model:    config.Model,
+			Language: config.Lang,
+			Prompt:   config.Prompt,
 		}
 	}
 
-	return l.conn.SendMessage(ctx, openairt.SessionUpdateEvent{
-		EventBase: openairt.EventBase{
-			EventID: "Initial update",
-		},
+	return openairt.SessionUpdateEvent{
 		Session: openairt.SessionUnion{
 			Transcription: &openairt.TranscriptionSession{
 				Audio: &openairt.TranscriptionSessionAudio{
@@ -38,7 +40,7 @@ func (l *Listener) startTranscriptionSession(ctx context.Context) error {
 				},
 			},
 		},
-	})
+	}
 }
 
 func (l *Listener) runAudioTranscription() {
@@ -52,6 +54,13 @@ func (l *Listener) runAudioTranscription() {
 }
 
 func (l *Listener) ReceiveTranscriptionMessages() {
+	// speechStopped is when the server last detected the end of speech,
+	// for the latency metrics; zero once its transcript has been output.
+	var speechStopped time.Time
+	// speechStarted is when the server detected speech and spoken how long
+	// the last utterance was, for the history
+	var speechStarted time.Time
+	var spoken time.Duration
 	for {
 		msg, err := l.conn.ReadMessage(l.ctx)
 		if err != nil {
@@ -62,6 +71,7 @@ func (l *Listener) ReceiveTranscriptionMessages() {
 				return
 			}
 			log.Println("Listener.ReceiveTranscriptionMessages: error receiving message, retrying: ", err)
+			metricReadRetries.Inc()
 			continue
 		}
 		log.Println("Listener.ReceiveTranscriptionMessages: receiving message: ", msg.ServerEventType())
@@ -70,13 +80,25 @@ func (l *Listener) ReceiveTranscriptionMessages() {
 		case openairt.ServerEventTypeInputAudioBufferSpeechStarted:
 			log.Println("Listener.ReceiveTranscriptionMessages: speech detected")
 			l.config.UI.Send(&gui.ShowSpeechDetectedMsg{})
+			speechStarted = time.Now()
 		case openairt.ServerEventTypeInputAudioBufferSpeechStopped:
 			log.Println("Listener.ReceiveTranscriptionMessages: speech stopped, transcribing")
 			l.config.UI.Send(&gui.ShowTranscribingMsg{})
+			speechStopped = time.Now()
+			if !speechStarted.IsZero() {
+				spoken = speechStopped.Sub(speechStarted)
+			}
 		case openairt.ServerEventTypeResponseOutputAudioTranscriptDone:
 			text = msg.(openairt.ResponseOutputAudioTranscriptDoneEvent).Transcript
+		case openairt.ServerEventTypeConversationItemInputAudioTranscriptionDelta:
+			if err := l.receivePartial(msg.(openairt.ConversationItemInputAudioTranscriptionDeltaEvent)); err != nil {
+				l.inputFailed("type partial transcript", err)
+				return
+			}
+			continue
 		case openairt.ServerEventTypeConversationItemInputAudioTranscriptionCompleted:
 			text = msg.(openairt.ConversationItemInputAudioTranscriptionCompletedEvent).Transcript
+			l.partialItem, l.partial = "", ""
 		case openairt.ServerEventTypeError:
 			log.Println("Listener.ReceiveTranscriptionMessages: server error: ", msg.(openairt.ErrorEvent).Error.Message)
 			continue
@@ -84,38 +106,232 @@ func (l *Listener) ReceiveTranscriptionMessages() {
 			continue
 		}
 		if text == "" {
+			if err := l.erasePartial(); err != nil {
+				l.inputFailed("erase partial transcript", err)
+				return
+			}
 			continue
 		}
-		l.config.UI.Send(&gui.HideMsg{})
-		l.config.UI.Send(&gui.ShowTranscriptMsg{Text: text, IsUser: true})
-		log.Println("Listener.ReceiveTranscriptionMessages: received transcribed text: ", text)
-		if l.config.OutputFile != "" {
-			f, err := os.OpenFile(l.config.OutputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				log.Printf("Failed to open output file %s: %v\n", l.config.OutputFile, err)
-				continue
-			}
-			if _, err := fmt.Fprintln(f, text); err != nil {
-				log.Printf("Failed to write to output file: %v\n", err)
-			}
-			if err := f.Close(); err != nil {
-				log.Printf("Failed to close output file: %v\n", err)
+		metricTranscripts.Inc()
+		if !speechStopped.IsZero() {
+			metricTranscriptionLatency.ObserveSince(speechStopped)
+		}
+		if text = l.filterCodeWords(text); text == "" {
+			if err := l.erasePartial(); err != nil {
+				l.inputFailed("erase partial transcript", err)
+				return
 			}
+			speechStopped = time.Time{}
 			continue
 		}
-		log.Printf("Listener.ReceiveTranscriptionMessages: typing text: %q", text)
-		if l.config.InputController == nil {
-			log.Println("Listener.ReceiveTranscriptionMessages: no input controller available, cannot type text")
+		outputStart := time.Now()
+		if l.codeWords == nil || l.codeWords.Active() {
+			l.config.UI.Send(&gui.HideMsg{})
+		}
+		if entry, ok := l.matchVoiceCommand(text); ok {
+			err := l.erasePartial()
+			if err == nil {
+				err = l.runVoiceCommand(entry, text)
+			}
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				l.errCh <- fmt.Errorf("voice command: %w", err)
+				l.cancel()
+				return
+			}
+			speechStopped = time.Time{}
 			continue
 		}
-		if err := l.config.InputController.TypeText(l.ctx, text); err != nil {
-			if errors.Is(err, context.Canceled) {
+		raw := text
+		text = correctTranscript(l.ctx, l.corrector, text)
+		if text = l.postproc.Process(text); text == "" {
+			log.Println("Listener.ReceiveTranscriptionMessages: nothing left after post-processing")
+			if err := l.erasePartial(); err != nil {
+				l.inputFailed("erase partial transcript", err)
 				return
 			}
-			l.errCh <- fmt.Errorf("type text: %w", err)
-			l.cancel()
+			speechStopped = time.Time{}
+			continue
+		}
+		transcript := &gui.ShowTranscriptMsg{Text: text, IsUser: true}
+		if text != raw {
+			transcript.Raw = raw
+		}
+		l.config.UI.Send(transcript)
+		recordHistory(l.liveConfig(), history.Entry{
+			Kind: history.KindTranscript, Text: text, Raw: transcript.Raw, DurationMs: spoken.Milliseconds()})
+		log.Printf("Listener.ReceiveTranscriptionMessages: outputting text to %s: %q", l.config.Output, text)
+		if err := l.outputTranscript(text); err != nil {
+			l.inputFailed("output transcript", err)
 			return
 		}
-		log.Println("Listener.ReceiveTranscriptionMessages: text typed successfully")
+		observeOutput(outputStart, speechStopped)
+		speechStopped = time.Time{}
+	}
+}
+
+// outputTranscript outputs text and records what was typed, so it can be
+// undone. The input is held throughout, so an undo sees text recorded once
+// it is typed. It fails only if the keyboard or clipboard did, or the
+// session ended.
+func (l *Listener) outputTranscript(text string) error {
+	l.inputMu.Lock()
+	defer l.inputMu.Unlock()
+	err := l.output.Output(l.ctx, text)
+	var inputErr *output.InputError
+	if errors.As(err, &inputErr) {
+		// What was typed, if anything, is unknown
+		l.config.Undo.Clear()
+		return err
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		// The file, command and HTTP sinks are best effort
+		log.Println("Listener.outputTranscript: output failed: ", err)
+	}
+	if typesText(l.liveConfig()) {
+		log.Println("Listener.outputTranscript: text output successfully")
+		recordTyped(l.ctx, l.liveConfig(), text)
+	}
+	return nil
+}
+
+// receivePartial accumulates a transcript delta, shows the transcript so far
+// and, if partial typing is enabled, types it. Nothing is shown while
+// dictation is paused.
+func (l *Listener) receivePartial(ev openairt.ConversationItemInputAudioTranscriptionDeltaEvent) error {
+	l.inputMu.Lock()
+	defer l.inputMu.Unlock()
+	if ev.ItemID != l.partialItem {
+		l.partialItem, l.partial = ev.ItemID, ""
+		// A new utterance; anything typed for the previous one stays,
+		// unrecorded, so what was recorded can no longer be undone
+		if l.keyboard != nil && l.keyboard.KeepPartial() {
+			l.config.Undo.Clear()
+		}
+	}
+	l.partial += ev.Delta
+	if l.codeWords != nil && !l.codeWords.Active() {
+		return nil
+	}
+	l.config.UI.Send(&gui.ShowPartialTranscriptMsg{Text: l.partial})
+
+	if !l.config.TypePartial || l.config.Mode == "assistant" || l.keyboard == nil {
+		return nil
+	}
+	return l.keyboard.Partial(l.ctx, l.partial)
+}
+
+// inputFailed ends the session after typing failed, unless it failed
+// because the session already ended.
+func (l *Listener) inputFailed(what string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	l.errCh <- fmt.Errorf("%s: %w", what, err)
+	l.cancel()
+}
+
+// erasePartial deletes the partial transcript typed for an utterance which
+// turned out to have nothing to type.
+func (l *Listener) erasePartial() error {
+	if l.keyboard == nil {
+		return nil
+	}
+	l.inputMu.Lock()
+	defer l.inputMu.Unlock()
+	n, err := l.keyboard.Erase(l.ctx)
+	if n > 0 {
+		log.Printf("Listener.erasePartial: erased %d characters", n)
+	}
+	return err
+}
+
+// filterCodeWords returns the part of text to output, without the code
+// words, and updates the status when dictation starts or stops. While
+// dictation is paused the status returns to paused after each transcript.
+func (l *Listener) filterCodeWords(text string) string {
+	if l.codeWords == nil {
+		return text
+	}
+	out, changed := l.codeWords.Filter(text)
+	if l.codeWords.Active() {
+		if changed {
+			log.Println("Listener.filterCodeWords: dictation started")
+			l.config.UI.Send(&gui.ShowDictationStartedMsg{})
+		}
+		return out
+	}
+	if changed {
+		log.Println("Listener.filterCodeWords: dictation stopped")
+	} else {
+		log.Printf("Listener.filterCodeWords: dictation paused, dropping text: %q", text)
+	}
+	l.config.UI.Send(&gui.ShowDictationPausedMsg{StartPhrase: l.config.CodeWordStart})
+	return out
+}
+
+// voiceCommandMatcher returns the voice commands for the session's
+// language, with those from the voice commands file added.
+func voiceCommandMatcher(config ListenConfig) *voicecmd.Matcher {
+	table := voicecmd.Default
+	if config.VoiceCommandsFile != "" {
+		file, err := voicecmd.Load(config.VoiceCommandsFile)
+		if err != nil {
+			log.Println("voiceCommandMatcher: using the built-in voice commands: ", err)
+		} else {
+			table = table.Merge(file)
+		}
+	}
+	m := table.Matcher(config.Lang)
+	if m == nil {
+		log.Printf("voiceCommandMatcher: no voice commands for language %q", config.Lang)
+	}
+	return m
+}
+
+// matchVoiceCommand returns the voice command text consists of, if voice
+// commands are enabled and text would otherwise be typed.
+func (l *Listener) matchVoiceCommand(text string) (voicecmd.Entry, bool) {
+	l.mu.Lock()
+	m := l.voiceCommands
+	l.mu.Unlock()
+	if m == nil || !typesText(l.liveConfig()) || l.config.InputController == nil {
+		return voicecmd.Entry{}, false
+	}
+	return m.Match(text)
+}
+
+// runVoiceCommand executes a voice command instead of typing its text.
+func (l *Listener) runVoiceCommand(entry voicecmd.Entry, text string) error {
+	l.inputMu.Lock()
+	defer l.inputMu.Unlock()
+	cmds := entry.Commands
+	if entry.Delete {
+		var err error
+		if cmds, _, err = undoCommands(l.ctx, l.liveConfig()); err != nil {
+			log.Printf("Listener.runVoiceCommand: %q: not deleting: %v", text, err)
+			return nil
+		}
+	} else {
+		// Only text typed directly before can be deleted, as the keys
+		// may move the cursor
+		l.config.Undo.Clear()
+	}
+
+	log.Printf("Listener.runVoiceCommand: %q: executing %d input commands", text, len(cmds))
+	return l.config.InputController.ExecuteCommands(l.ctx, cmds)
+}
+
+// observeOutput records how long outputting a transcript took and, if the
+// end of its speech is known, the end-to-end latency.
+func observeOutput(output, speechStopped time.Time) {
+	metricOutputDuration.ObserveSince(output)
+	if !speechStopped.IsZero() {
+		metricEndToEndLatency.ObserveSince(speechStopped)
 	}
 }
@@ -37,7 +37,11 @@ func tuiCommand(args []string) {
 		return
 	}
 
-	socketPath := ipc.SocketPath()
+	instance, err := resolveInstance(listenArgs)
+	if err != nil {
+		log.Fatalln("tui: ", err)
+	}
+	socketPath := ipc.SocketPath(instance)
 
 	// Launch listen as subprocess
 	cmdArgs := []string{"listen", "--socket", socketPath}
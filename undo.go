@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/richiejp/VoxInput/internal/input"
+	"github.com/richiejp/VoxInput/internal/ipc"
+	"github.com/richiejp/VoxInput/internal/output"
+	"github.com/richiejp/VoxInput/internal/undo"
+)
+
+// focusCommandTimeout bounds how long the undo focus command may take.
+const focusCommandTimeout = 2 * time.Second
+
+// focusedWindow returns the output of the undo focus command, which
+// identifies the focused window, or "" when no command is configured.
+func focusedWindow(ctx context.Context, command string) (string, error) {
+	if command == "" {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, focusCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "sh", "-c", command).Output()
+	if err != nil {
+		return "", fmt.Errorf("focus command: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// recordTyped remembers the text typed for an utterance, so it can be
+// undone. If the focused window is unknown, nothing typed so far can be
+// undone safely.
+func recordTyped(ctx context.Context, config ListenConfig, text string) {
+	focus, err := focusedWindow(ctx, config.UndoFocusCommand)
+	if err != nil {
+		log.Println("recordTyped: the text cannot be undone: ", err)
+		config.Undo.Clear()
+		return
+	}
+	config.Undo.Typed(text, focus)
+}
+
+// undoCommands returns the key presses which erase the text typed for the
+// last utterance, or an error if that is not safe.
+func undoCommands(ctx context.Context, config ListenConfig) ([]input.Command, string, error) {
+	if config.InputController == nil {
+		return nil, "", errors.New("no input controller")
+	}
+	focus, err := focusedWindow(ctx, config.UndoFocusCommand)
+	if err != nil {
+		return nil, "", err
+	}
+	return config.Undo.Undo(focus, undo.Limits{MaxChars: config.UndoMaxChars, MaxAge: config.UndoTimeout})
+}
+
+// handleUndo answers an IPC undo command by erasing the text typed for the
+// last utterance. It runs the focus command and presses keys, so it is run
+// off the listen loop, with a context which ends with the session. l is the
+// session being recorded, or nil; its input is held while undoing.
+func handleUndo(ctx context.Context, cmd ipc.Command, config ListenConfig, l *Listener) {
+	if l != nil {
+		l.inputMu.Lock()
+		defer l.inputMu.Unlock()
+	}
+	cmds, text, err := undoCommands(ctx, config)
+	if err != nil {
+		log.Println("listen: undo refused: ", err)
+		cmd.ReplyError(err)
+		return
+	}
+	if l != nil && l.keyboard != nil {
+		err = l.keyboard.Undo(ctx, cmds)
+	} else {
+		err = config.InputController.ExecuteCommands(ctx, cmds)
+	}
+	if errors.Is(err, output.ErrPartialTyped) {
+		// The text typed before is no longer at the cursor
+		config.Undo.Clear()
+		log.Println("listen: undo refused: ", err)
+		cmd.ReplyError(err)
+		return
+	}
+	if err != nil {
+		cmd.ReplyError(fmt.Errorf("undo: %w", err))
+		return
+	}
+	log.Printf("listen: undo: erased %q", text)
+	cmd.Reply(ipc.Event{Text: fmt.Sprintf("erased %d characters", len(cmds))})
+}